@@ -0,0 +1,294 @@
+package cwapi
+
+import (
+	"context"
+	"fmt"
+	"github.com/streadway/amqp"
+	"sync"
+	"time"
+)
+
+// Default transport, talks to Chat Wars broker over AMQP.
+type AMQPTransport struct {
+	URL    string
+	Config amqp.Config
+}
+
+// Opens new AMQP connection with channel for publishing, every consumer gets its own channel.
+func (t *AMQPTransport) Dial() (Session, error) {
+	config := t.Config
+	// the same defaults as amqp.Dial uses
+	if config.Heartbeat == 0 {
+		config.Heartbeat = 10 * time.Second
+	}
+	if config.Locale == "" {
+		config.Locale = "en_US"
+	}
+
+	conn, err := amqp.DialConfig(t.URL, config)
+	if err != nil {
+		return nil, err
+	}
+
+	chForPublish, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// wait for broker acknowledgement on every publish
+	if err := chForPublish.Confirm(false); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	s := &amqpSession{
+		connection:        conn,
+		channelForPublish: chForPublish,
+		confirms:          chForPublish.NotifyPublish(make(chan amqp.Confirmation, 1)),
+		returns:           chForPublish.NotifyReturn(make(chan amqp.Return, 1)),
+		publishing:        make(chan struct{}, 1),
+		closed:            make(chan error, 1),
+	}
+	go s.watch()
+
+	return s, nil
+}
+
+type amqpSession struct {
+	connection        *amqp.Connection
+	channelForPublish *amqp.Channel
+	closed            chan error
+
+	// publishes are serialized by semaphore, so publisher waiting for its turn can give up once ctx is done
+	publishing chan struct{}
+	// delivery tag of the last published message, confirmations of abandoned publishes are skipped by it
+	published uint64
+	confirms  chan amqp.Confirmation
+	returns   chan amqp.Return
+
+	consumersMu sync.Mutex
+	consumers   []amqpConsumer
+}
+
+type amqpConsumer struct {
+	channel *amqp.Channel
+	tag     string
+}
+
+// Waits for connection or publish channel to be closed, consumer channels are watched by Consume.
+func (s *amqpSession) watch() {
+	var reason error
+
+	select {
+	case err := <-s.connection.NotifyClose(make(chan *amqp.Error, 1)):
+		reason = closeReason("connection", err)
+	case err := <-s.channelForPublish.NotifyClose(make(chan *amqp.Error, 1)):
+		reason = closeReason("publish channel", err)
+	}
+
+	s.fail(reason)
+}
+
+// Reports the first reason session became unusable.
+func (s *amqpSession) fail(reason error) {
+	select {
+	case s.closed <- reason:
+	default:
+	}
+}
+
+// Publishes message as mandatory and waits for broker confirmation until ctx is done.
+// Unroutable message is returned by broker before confirmation, it's reported as *ReturnedError.
+func (s *amqpSession) Publish(ctx context.Context, msg Publishing) error {
+	select {
+	case s.publishing <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-s.publishing }()
+
+	err := s.channelForPublish.Publish(
+		msg.Exchange,
+		msg.RoutingKey,
+		true,
+		false,
+		amqp.Publishing{
+			ContentType:   "text/plain",
+			CorrelationId: msg.CorrelationID,
+			MessageId:     msg.CorrelationID,
+			Body:          msg.Body,
+		},
+	)
+	// If channel closed
+	if e, ok := err.(*amqp.Error); ok && e.Code == amqp.ChannelError {
+		return ErrClosed
+	}
+	if err != nil {
+		return err
+	}
+	s.published++
+	tag := s.published
+
+	var returned *ReturnedError
+	returns := s.returns
+	for {
+		select {
+		case r, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			// return of abandoned publish has different message ID
+			if r.MessageId == msg.CorrelationID {
+				returned = newReturnedError(r)
+			}
+		case confirm, ok := <-s.confirms:
+			if !ok {
+				return ErrClosed
+			}
+			if confirm.DeliveryTag < tag {
+				// confirmation of publish abandoned when its ctx was done
+				continue
+			}
+			// return is dispatched before ack, but select may pick confirm first,
+			// so take buffered return now instead of blaming the next publish
+			if returned == nil && returns != nil {
+				select {
+				case r, ok := <-returns:
+					if ok && r.MessageId == msg.CorrelationID {
+						returned = newReturnedError(r)
+					}
+				default:
+				}
+			}
+			if returned != nil {
+				return returned
+			}
+			if !confirm.Ack {
+				return ErrNacked
+			}
+			return nil
+		case <-ctx.Done():
+			// the next publish skips confirmation of this one
+			return ctx.Err()
+		}
+	}
+}
+
+func newReturnedError(r amqp.Return) *ReturnedError {
+	return &ReturnedError{
+		Exchange:   r.Exchange,
+		RoutingKey: r.RoutingKey,
+		Code:       int(r.ReplyCode),
+		Reason:     r.ReplyText,
+	}
+}
+
+// Consumes queue on its own channel with QoS set if prefetch is positive. Broker closes channel
+// if consuming fails, e.g. queue doesn't exist, so failed consume doesn't affect other consumers.
+func (s *amqpSession) Consume(queue string, autoAck bool, prefetch int) (<-chan Delivery, error) {
+	channel, err := s.openConsumerChannel(prefetch)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := newCorrelationID()
+	deliveries, err := channel.Consume(
+		queue,
+		tag,
+		autoAck,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		channel.Close()
+		return nil, err
+	}
+	s.watchConsumerChannel(channel)
+
+	s.consumersMu.Lock()
+	s.consumers = append(s.consumers, amqpConsumer{channel, tag})
+	s.consumersMu.Unlock()
+
+	out := make(chan Delivery)
+	go func() {
+		defer close(out)
+
+		for d := range deliveries {
+			out <- Delivery{
+				Acknowledger:  d.Acknowledger,
+				RoutingKey:    d.RoutingKey,
+				CorrelationID: d.CorrelationId,
+				DeliveryTag:   d.DeliveryTag,
+				Body:          d.Body,
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// QoS applies to the whole channel, it's one more reason for every consumer to get its own one.
+func (s *amqpSession) openConsumerChannel(prefetch int) (*amqp.Channel, error) {
+	channel, err := s.connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+
+	if prefetch > 0 {
+		if err := channel.Qos(prefetch, 0, false); err != nil {
+			channel.Close()
+			return nil, err
+		}
+	}
+
+	return channel, nil
+}
+
+// Reports session failure once channel of running consumer is closed or consumer is cancelled by server.
+func (s *amqpSession) watchConsumerChannel(channel *amqp.Channel) {
+	closed := channel.NotifyClose(make(chan *amqp.Error, 1))
+	cancelled := channel.NotifyCancel(make(chan string, 1))
+	go func() {
+		select {
+		case err := <-closed:
+			s.fail(closeReason("consumer channel", err))
+		case tag := <-cancelled:
+			s.fail(fmt.Errorf("consumer %s was cancelled by server", tag))
+		}
+	}()
+}
+
+func (s *amqpSession) Cancel() error {
+	s.consumersMu.Lock()
+	defer s.consumersMu.Unlock()
+
+	for _, consumer := range s.consumers {
+		if err := consumer.channel.Cancel(consumer.tag, false); err != nil {
+			return err
+		}
+	}
+	s.consumers = nil
+
+	return nil
+}
+
+func (s *amqpSession) NotifyClose() <-chan error {
+	return s.closed
+}
+
+// Closes connection together with its channels and consumers.
+func (s *amqpSession) Close() error {
+	return s.connection.Close()
+}
+
+// Converts close notification to error, graceful close is reported as nil *amqp.Error.
+func closeReason(source string, err *amqp.Error) error {
+	if err == nil {
+		return fmt.Errorf("%s closed", source)
+	}
+	return fmt.Errorf("%s closed: %s", source, err)
+}
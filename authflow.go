@@ -0,0 +1,229 @@
+package cwapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// Returned by AuthFlow when Start wasn't called for the user or flow was cancelled.
+	ErrNoAuthFlow = errors.New("cwapi: authorization was not started for user")
+	// Returned by AuthFlow.Submit when code was requested too long ago, call Start again.
+	ErrAuthExpired = errors.New("cwapi: authorization code expired")
+	// Returned by AuthFlow.Submit when user entered wrong code too many times, call Start again.
+	ErrAuthAttemptsExceeded = errors.New("cwapi: too many invalid authorization codes")
+	// Returned by AuthFlow.Submit when previous code of the user is still being checked.
+	ErrAuthInProgress = errors.New("cwapi: authorization code is already submitted")
+)
+
+type AuthState string
+
+const (
+	// createAuthCode was sent, waiting for the code from user
+	AuthCodeRequested AuthState = "code_requested"
+	// Code was submitted, waiting for grantToken response
+	AuthCodeSubmitted AuthState = "code_submitted"
+	// Token was granted
+	AuthGranted AuthState = "granted"
+	// Code wasn't submitted in time
+	AuthExpired AuthState = "expired"
+	// User entered wrong code too many times
+	AuthFailed AuthState = "failed"
+)
+
+// Authorization progress of single user.
+type AuthStatus struct {
+	UserID      int
+	State       AuthState
+	RequestedAt time.Time
+	ExpiresAt   time.Time
+	// Number of codes server answered with InvalidCode
+	Attempts int
+	// Set once State is AuthGranted
+	Token string
+}
+
+// Drives createAuthCode → grantToken authorization of many users at once. It doesn't care where code
+// comes from, so the same flow serves bot conversation, web form or CLI:
+//
+//	flow := cwapi.NewAuthFlow(client, 5*time.Minute, 3)
+//
+//	// user pressed "Authorize"
+//	flow.Start(ctx, userID)
+//
+//	// user sent the code
+//	status, err := flow.Submit(ctx, userID, code)
+//	switch {
+//	case err == nil:
+//		log.Println("token", status.Token)
+//	case cwapi.IsInvalidCode(err):
+//		log.Println("wrong code, attempts left", 3-status.Attempts)
+//	case err == cwapi.ErrAuthExpired, err == cwapi.ErrAuthAttemptsExceeded:
+//		log.Println("start again")
+//	}
+//
+// Granted tokens are put to TokenStore of the client, if any. AuthFlow is safe for concurrent use.
+type AuthFlow struct {
+	client      *Client
+	ttl         time.Duration
+	maxAttempts int
+
+	mu        sync.Mutex
+	statuses  map[int]*AuthStatus
+	listeners []chan AuthStatus
+}
+
+// Creates flow. Code is valid for ttl after it was requested (defaults to 5 minutes),
+// flow fails after maxAttempts invalid codes (defaults to 3).
+func NewAuthFlow(client *Client, ttl time.Duration, maxAttempts int) *AuthFlow {
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+
+	return &AuthFlow{
+		client:      client,
+		ttl:         ttl,
+		maxAttempts: maxAttempts,
+		statuses:    make(map[int]*AuthStatus),
+	}
+}
+
+// Registers listener for state changes of every user.
+// Statuses are sent without blocking, so use buffered channel or they will be dropped.
+func (f *AuthFlow) NotifyState(ch chan AuthStatus) chan AuthStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.listeners = append(f.listeners, ch)
+	return ch
+}
+
+// Requests authorization code for the user, previous flow of the user is discarded.
+func (f *AuthFlow) Start(ctx context.Context, userID int) (AuthStatus, error) {
+	if _, err := f.client.CreateAuthCodeSyncContext(ctx, userID); err != nil {
+		return AuthStatus{UserID: userID}, err
+	}
+
+	now := time.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s := &AuthStatus{
+		UserID:      userID,
+		State:       AuthCodeRequested,
+		RequestedAt: now,
+		ExpiresAt:   now.Add(f.ttl),
+	}
+	f.statuses[userID] = s
+	f.notifyLocked(s)
+
+	return *s, nil
+}
+
+// Grants token with the code user entered. Server answer InvalidCode is returned as APIError,
+// user may try again until attempts are exhausted.
+func (f *AuthFlow) Submit(ctx context.Context, userID int, code string) (AuthStatus, error) {
+	f.mu.Lock()
+	s, found := f.statuses[userID]
+	if !found {
+		f.mu.Unlock()
+		return AuthStatus{UserID: userID}, ErrNoAuthFlow
+	}
+
+	f.expireLocked(s)
+	switch s.State {
+	case AuthExpired:
+		f.mu.Unlock()
+		return *s, ErrAuthExpired
+	case AuthFailed:
+		f.mu.Unlock()
+		return *s, ErrAuthAttemptsExceeded
+	case AuthCodeSubmitted:
+		f.mu.Unlock()
+		return *s, ErrAuthInProgress
+	case AuthGranted:
+		f.mu.Unlock()
+		return *s, nil
+	}
+
+	s.State = AuthCodeSubmitted
+	f.notifyLocked(s)
+	f.mu.Unlock()
+
+	res, err := f.client.GrantTokenSyncContext(ctx, userID, code)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// flow was restarted or cancelled meanwhile
+	if f.statuses[userID] != s {
+		if err != nil {
+			return *s, err
+		}
+		return *s, ErrNoAuthFlow
+	}
+
+	switch {
+	case err == nil:
+		s.State = AuthGranted
+		s.Token = res.Payload.ResGrantToken.Token
+	case IsInvalidCode(err):
+		s.Attempts++
+		s.State = AuthCodeRequested
+		if s.Attempts >= f.maxAttempts {
+			s.State = AuthFailed
+			err = ErrAuthAttemptsExceeded
+		}
+	default:
+		// e.g. timeout, user may submit the code again
+		s.State = AuthCodeRequested
+	}
+	f.notifyLocked(s)
+
+	return *s, err
+}
+
+// Returns authorization progress of the user.
+func (f *AuthFlow) Status(userID int) (AuthStatus, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, found := f.statuses[userID]
+	if !found {
+		return AuthStatus{UserID: userID}, false
+	}
+
+	f.expireLocked(s)
+	return *s, true
+}
+
+// Forgets the user, e.g. once token is saved or user gave up.
+func (f *AuthFlow) Cancel(userID int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.statuses, userID)
+}
+
+// Marks flow expired if code wasn't submitted in time.
+func (f *AuthFlow) expireLocked(s *AuthStatus) {
+	if s.State == AuthCodeRequested && time.Now().After(s.ExpiresAt) {
+		s.State = AuthExpired
+		f.notifyLocked(s)
+	}
+}
+
+func (f *AuthFlow) notifyLocked(s *AuthStatus) {
+	for _, ch := range f.listeners {
+		select {
+		case ch <- *s:
+		default:
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package cwapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Decides what happens when application doesn't read stream channel fast enough and its buffer is full.
+type OverflowPolicy int
+
+const (
+	// Waits until application reads the channel. It stalls acking of the stream, default one
+	OverflowBlock OverflowPolicy = iota
+	// Drops message which doesn't fit into buffer
+	OverflowDropNewest
+	// Drops the oldest message from the buffer to make room for the new one.
+	// Unbuffered channel has no oldest message, so the new one is dropped then
+	OverflowDropOldest
+	// Writes messages to file in spill directory and sends them to the channel later, in the same order.
+	// Messages left on disk on Close are sent after next start.
+	OverflowSpill
+)
+
+// Counters of the stream, see Client.Stats.
+type StreamStats struct {
+	// Sent to the channel
+	Delivered uint64
+	// Dropped according to the overflow policy
+	Dropped uint64
+	// Written to the spill file
+	Spilled uint64
+}
+
+// Returns counters of the stream.
+func (c *Client) Stats(stream Stream) StreamStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s, found := c.sinks[stream]
+	if !found {
+		return StreamStats{}
+	}
+
+	return StreamStats{
+		Delivered: atomic.LoadUint64(&s.delivered),
+		Dropped:   atomic.LoadUint64(&s.dropped),
+		Spilled:   atomic.LoadUint64(&s.spilled),
+	}
+}
+
+// Sends stream values to the application channel following overflow policy.
+type sink struct {
+	// accessed atomically, keep them first for 64-bit alignment
+	delivered uint64
+	dropped   uint64
+	spilled   uint64
+
+	stream Stream
+	ch     reflect.Value
+	policy OverflowPolicy
+	decode DecodeFunc
+	spill  *spill
+}
+
+// Creates sink for the stream channel. Spilled messages left from previous run are delivered after startSink.
+func (c *Client) newSink(stream Stream, ch interface{}, decode DecodeFunc, policy OverflowPolicy) (*sink, error) {
+	s := &sink{
+		stream: stream,
+		ch:     reflect.ValueOf(ch),
+		policy: policy,
+		decode: decode,
+	}
+
+	if s.policy == OverflowSpill {
+		path := filepath.Join(c.spillDir, fmt.Sprintf("cwapi_%s_%s.spill", c.User, stream))
+		sp, err := openSpill(path)
+		if err != nil {
+			return nil, err
+		}
+		s.spill = sp
+	}
+
+	c.mu.Lock()
+	if c.sinks == nil {
+		c.sinks = make(map[Stream]*sink)
+	}
+	c.sinks[stream] = s
+	c.mu.Unlock()
+
+	return s, nil
+}
+
+// Starts delivering spilled messages, if any, once consumer of the stream is running.
+func (c *Client) startSink(s *sink) error {
+	if s.spill == nil {
+		return nil
+	}
+
+	if err := c.goConsume(func() { c.drainSpill(s) }); err != nil {
+		s.spill.close()
+		return err
+	}
+	return nil
+}
+
+// Forgets sink of subscription which failed to start.
+func (c *Client) removeSink(s *sink) {
+	c.mu.Lock()
+	if c.sinks[s.stream] == s {
+		delete(c.sinks, s.stream)
+	}
+	c.mu.Unlock()
+
+	if s.spill != nil {
+		s.spill.close()
+	}
+}
+
+// Puts value to the channel, body is used to spill it. Returns false if abort was closed
+// and value was neither sent, dropped nor spilled, so delivery should be requeued.
+func (s *sink) put(v interface{}, body []byte, abort <-chan struct{}) bool {
+	switch s.policy {
+	case OverflowDropNewest:
+		if !s.trySend(v) {
+			atomic.AddUint64(&s.dropped, 1)
+		}
+		return true
+	case OverflowDropOldest:
+		if s.ch.Cap() == 0 {
+			// unbuffered channel has nothing to drop, behave like OverflowDropNewest instead of spinning
+			if !s.trySend(v) {
+				atomic.AddUint64(&s.dropped, 1)
+			}
+			return true
+		}
+		for !s.trySend(v) {
+			if _, ok := s.ch.TryRecv(); ok {
+				atomic.AddUint64(&s.dropped, 1)
+			}
+		}
+		return true
+	case OverflowSpill:
+		// keep order: while something is on disk, new values go there as well
+		if s.spill.empty() && s.trySend(v) {
+			return true
+		}
+		if err := s.spill.write(body); err != nil {
+			// can't spill, fallback to blocking
+			return s.send(v, abort)
+		}
+		atomic.AddUint64(&s.spilled, 1)
+		return true
+	default:
+		return s.send(v, abort)
+	}
+}
+
+// Sends value without blocking, returns false if channel buffer is full.
+func (s *sink) trySend(v interface{}) bool {
+	if s.ch.TrySend(s.value(v)) {
+		atomic.AddUint64(&s.delivered, 1)
+		return true
+	}
+	return false
+}
+
+// Sends value waiting until application reads the channel or abort is closed.
+func (s *sink) send(v interface{}, abort <-chan struct{}) bool {
+	chosen, _, _ := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectSend, Chan: s.ch, Send: s.value(v)},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(abort)},
+	})
+	if chosen != 0 {
+		return false
+	}
+
+	atomic.AddUint64(&s.delivered, 1)
+	return true
+}
+
+// Converts value for sending, nil becomes zero value of channel element.
+func (s *sink) value(v interface{}) reflect.Value {
+	if v == nil {
+		return reflect.Zero(s.ch.Type().Elem())
+	}
+	return reflect.ValueOf(v)
+}
+
+// Sends spilled messages to the channel until client starts closing.
+func (c *Client) drainSpill(s *sink) {
+	defer s.spill.close()
+
+	for {
+		body, ok := s.spill.next(c.done)
+		if !ok {
+			return
+		}
+
+		v, err := s.decode(body)
+		if err != nil {
+			c.handlePoison(s.stream, body, err)
+			s.spill.commit()
+			continue
+		}
+
+		if !s.send(v, c.done) {
+			return
+		}
+		s.spill.commit()
+	}
+}
+
+// File with messages waiting for the room in the channel, one JSON encoded body per line.
+type spill struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	reader  *bufio.Reader
+	pending int
+	wake    chan struct{}
+	// line returned by next and not committed yet
+	current []byte
+}
+
+// Opens spill file, counts messages left there by previous run.
+func openSpill(path string) (*spill, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &spill{
+		path:   path,
+		file:   file,
+		reader: bufio.NewReader(file),
+		wake:   make(chan struct{}, 1),
+	}
+
+	// count leftovers, then rewind for reading
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<26)
+	for scanner.Scan() {
+		s.pending++
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if s.pending > 0 {
+		s.wake <- struct{}{}
+	}
+
+	return s, nil
+}
+
+func (s *spill) empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pending == 0
+}
+
+// Appends message to the end of file.
+func (s *spill) write(body []byte) error {
+	line, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.WriteAt(line, s.size()); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.pending++
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Returns the oldest message without removing it, waits for one if there are none.
+// Returns false once done is closed.
+func (s *spill) next(done <-chan struct{}) ([]byte, bool) {
+	for {
+		s.mu.Lock()
+		if s.pending > 0 {
+			line, err := s.reader.ReadBytes('\n')
+			s.current = line
+			s.mu.Unlock()
+
+			var body []byte
+			if err == nil {
+				err = json.Unmarshal(bytes.TrimSpace(line), &body)
+			}
+			if err != nil {
+				// broken file, start over
+				s.reset()
+				continue
+			}
+			return body, true
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.wake:
+		case <-done:
+			return nil, false
+		}
+	}
+}
+
+// Removes message returned by next, empty file is truncated.
+func (s *spill) commit() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.current = nil
+	s.pending--
+	if s.pending == 0 {
+		s.truncateLocked()
+	}
+}
+
+func (s *spill) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.current = nil
+	s.pending = 0
+	s.truncateLocked()
+}
+
+func (s *spill) truncateLocked() {
+	s.file.Truncate(0)
+	s.file.Seek(0, io.SeekStart)
+	s.reader.Reset(s.file)
+}
+
+// Returns current file size.
+func (s *spill) size() int64 {
+	info, err := s.file.Stat()
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// Keeps only messages which weren't delivered yet and closes the file.
+func (s *spill) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rest, err := ioutil.ReadAll(s.reader)
+	if err == nil {
+		s.file.Truncate(0)
+		s.file.WriteAt(append(s.current, rest...), 0)
+	}
+	s.file.Close()
+}
@@ -0,0 +1,146 @@
+package cwapi
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// Waits until consumer has handled n deliveries of the stream.
+func waitHandled(t *testing.T, client *Client, stream Stream, n uint64) StreamStats {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stats := client.Stats(stream)
+		if stats.Delivered+stats.Dropped+stats.Spilled >= n {
+			return stats
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("only %+v of %d deliveries handled", stats, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOverflowDropNewest(t *testing.T) {
+	client, transport := newTestClient(t,
+		WithBufferSize(StreamDeals, 2),
+		WithOverflowPolicy(StreamDeals, OverflowDropNewest),
+	)
+	defer closeTestClient(client)
+
+	if err := client.InitDeals(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		transport.Deliver("login_deals", Deal{Quantity: i})
+	}
+
+	stats := waitHandled(t, client, StreamDeals, 5)
+	if stats.Delivered != 2 || stats.Dropped != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if first, second := <-client.Deals, <-client.Deals; first.Quantity != 0 || second.Quantity != 1 {
+		t.Fatalf("expected the oldest deals, got %d and %d", first.Quantity, second.Quantity)
+	}
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	client, transport := newTestClient(t,
+		WithBufferSize(StreamDeals, 2),
+		WithOverflowPolicy(StreamDeals, OverflowDropOldest),
+	)
+	defer closeTestClient(client)
+
+	if err := client.InitDeals(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		transport.Deliver("login_deals", Deal{Quantity: i})
+	}
+
+	stats := waitHandled(t, client, StreamDeals, 5+3)
+	if stats.Dropped != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if first, second := <-client.Deals, <-client.Deals; first.Quantity != 3 || second.Quantity != 4 {
+		t.Fatalf("expected the newest deals, got %d and %d", first.Quantity, second.Quantity)
+	}
+}
+
+func TestOverflowDropOldestUnbuffered(t *testing.T) {
+	client, transport := newTestClient(t,
+		WithBufferSize(StreamDeals, 0),
+		WithOverflowPolicy(StreamDeals, OverflowDropOldest),
+	)
+	defer closeTestClient(client)
+
+	if err := client.InitDeals(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		transport.Deliver("login_deals", Deal{Quantity: i})
+	}
+
+	stats := waitHandled(t, client, StreamDeals, 3)
+	if stats.Dropped != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestOverflowSpillKeepsOrderAcrossRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cwapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	client, transport := newTestClient(t,
+		WithBufferSize(StreamOffers, 1),
+		WithOverflowPolicy(StreamOffers, OverflowSpill),
+		WithSpillDir(dir),
+	)
+	if err := client.InitOffers(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		transport.Deliver("login_offers", Offer{Quantity: i})
+	}
+
+	waitHandled(t, client, StreamOffers, 5)
+	for i := 0; i < 2; i++ {
+		if offer := <-client.Offers; offer.Quantity != i {
+			t.Fatalf("expected offer %d, got %d", i, offer.Quantity)
+		}
+	}
+	closeTestClient(client)
+	// whatever was already sent to the channel before Close is still there
+	next := 2
+	for offer := range client.Offers {
+		if offer.Quantity != next {
+			t.Fatalf("expected offer %d, got %d", next, offer.Quantity)
+		}
+		next++
+	}
+
+	// the rest is restored from spill file by the next client
+	client, _ = newTestClient(t,
+		WithOverflowPolicy(StreamOffers, OverflowSpill),
+		WithSpillDir(dir),
+	)
+	defer closeTestClient(client)
+	if err := client.InitOffers(); err != nil {
+		t.Fatal(err)
+	}
+
+	for ; next < 5; next++ {
+		select {
+		case offer := <-client.Offers:
+			if offer.Quantity != next {
+				t.Fatalf("expected offer %d, got %d", next, offer.Quantity)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("offer %d was not restored", next)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package cwapi
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Response returned by Call, payload is left as server sent it.
+type RawResponse struct {
+	UUID    string          `json:"uuid"`
+	Action  string          `json:"action"`
+	Result  string          `json:"result"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Returns constant with ResultEnum
+func (res *RawResponse) GetResultEnum() ResultEnum {
+	return (&Response{Result: res.Result}).GetResultEnum()
+}
+
+// Sends any action, including those this library doesn't wrap yet, and waits for the response until ctx is done.
+// Payload is marshalled to JSON, pass nil if action has none:
+//
+//	res, err := client.Call(ctx, "newAction", token, map[string]interface{}{
+//		"itemCode": "07",
+//	}, userID)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	var payload NewActionPayload
+//	json.Unmarshal(res.Payload, &payload)
+//
+// Like Sync methods, Call returns APIError along with the response if result is not Ok.
+// If server doesn't echo correlation ID, response is matched by action and userID, so pass the user
+// the token belongs to. Zero userID is taken from userId field of the payload, if any.
+func (c *Client) Call(ctx context.Context, action string, token string, payload interface{}, userID int) (*RawResponse, error) {
+	req := &Request{
+		Action: action,
+		Token:  token,
+	}
+
+	if payload != nil {
+		p, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		req.Payload = p
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if userID == 0 {
+		userID = payloadUserID(req.Payload)
+	}
+
+	rep, err := c.roundTrip(ctx, action, body, userID)
+	if rep == nil {
+		return nil, err
+	}
+
+	var res RawResponse
+	if err := json.Unmarshal(rep.body, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, err
+}
+
+// Returns userId field of the payload, zero if there is none.
+func payloadUserID(payload json.RawMessage) int {
+	var p struct {
+		UserID int `json:"userId"`
+	}
+	json.Unmarshal(payload, &p)
+	return p.UserID
+}
@@ -0,0 +1,52 @@
+package cwapi
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCallTokenOnlyActionWithoutCorrelationEcho(t *testing.T) {
+	client, transport := newTestClient(t)
+	defer closeTestClient(client)
+
+	go func() {
+		<-transport.Requests()
+		transport.DeliverRaw("login_i", "", []byte(`{"uuid":"x","action":"newAction","result":"Ok","payload":{"userId":5,"extra":[1,2]}}`))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	res, err := client.Call(ctx, "newAction", "tok", nil, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var payload struct {
+		Extra []int `json:"extra"`
+	}
+	if err := json.Unmarshal(res.Payload, &payload); err != nil || len(payload.Extra) != 2 {
+		t.Fatalf("unexpected payload %s: %v", res.Payload, err)
+	}
+}
+
+func TestCallReturnsAPIError(t *testing.T) {
+	client, transport := newTestClient(t)
+	defer closeTestClient(client)
+
+	go func() {
+		req := <-transport.Requests()
+		transport.Reply("login", req, Forbidden, map[string]interface{}{"userId": 5, "requiredOperation": "GetStock"})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	res, err := client.Call(ctx, "newAction", "tok", nil, 5)
+	e, ok := AsAPIError(err)
+	if !ok || e.Action != "newAction" || e.RequiredOperation != "GetStock" || res == nil {
+		t.Fatalf("unexpected result %v: %v", res, err)
+	}
+}
@@ -0,0 +1,175 @@
+package cwapi
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCloseConnectionDoesNotHangOnUnreadUpdates(t *testing.T) {
+	client, transport := newTestClient(t,
+		WithBufferSize(StreamUpdates, 1),
+		WithSyncTimeout(200*time.Millisecond),
+	)
+
+	for i := 0; i < 5; i++ {
+		transport.Deliver("login_i", Response{Action: "getInfo", Result: "Ok"})
+	}
+	// let consumer fill the buffer and block
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.CloseConnection()
+	}()
+
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected deadline error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("CloseConnection hangs")
+	}
+}
+
+func TestCloseDrainsReceivedDeliveries(t *testing.T) {
+	client, transport := newTestClient(t, WithBufferSize(StreamUpdates, 10))
+
+	for i := 0; i < 3; i++ {
+		transport.Deliver("login_i", Response{Action: "getInfo", Result: "Ok"})
+	}
+	waitHandled(t, client, StreamUpdates, 3)
+
+	if err := client.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(context.Background()); err != nil {
+		t.Fatalf("second Close returned %v", err)
+	}
+
+	var n int
+	for range client.Updates {
+		n++
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 drained updates, got %d", n)
+	}
+}
+
+func TestCloseFailsPendingSyncRequests(t *testing.T) {
+	client, transport := newTestClient(t)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.RequestStockSync("tok", 7)
+		done <- err
+	}()
+	<-transport.Requests()
+
+	closeTestClient(client)
+
+	select {
+	case err := <-done:
+		if err != ErrClientClosed {
+			t.Fatalf("expected ErrClientClosed, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Sync request was not failed by Close")
+	}
+}
+
+func TestReconnectAfterDisconnect(t *testing.T) {
+	client, transport := newTestClient(t, WithReconnectPolicy(ReconnectPolicy{
+		InitialInterval: 10 * time.Millisecond,
+		MaxInterval:     10 * time.Millisecond,
+		Multiplier:      1,
+	}))
+	defer closeTestClient(client)
+
+	events := client.NotifyConnectionState(make(chan ConnectionEvent, 10))
+	transport.Disconnect(errors.New("broker is gone"))
+
+	deadline := time.After(2 * time.Second)
+	for connected := false; !connected; {
+		select {
+		case e := <-events:
+			connected = e.State == StateConnected
+		case <-deadline:
+			t.Fatalf("client is %s after disconnect", client.State())
+		}
+	}
+
+	go func() {
+		req := <-transport.Requests()
+		transport.Reply("login", req, Ok, ResRequestStock{UserID: 7, Stock: map[string]int{"01": 1}})
+	}()
+	if _, err := client.RequestStockSync("tok", 7); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Refuses new sessions while down is set, like unreachable broker.
+type flakyTransport struct {
+	*MemoryTransport
+	down int32
+}
+
+func (t *flakyTransport) Dial() (Session, error) {
+	if atomic.LoadInt32(&t.down) == 1 {
+		return nil, errors.New("connection refused")
+	}
+	return t.MemoryTransport.Dial()
+}
+
+func TestSyncRequestHonorsDeadlineDuringOutage(t *testing.T) {
+	transport := &flakyTransport{MemoryTransport: NewMemoryTransport()}
+	client, err := NewClientWithTransport("login", transport, WithLogger(nil), WithSyncTimeout(2*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeTestClient(client)
+
+	atomic.StoreInt32(&transport.down, 1)
+	transport.Disconnect(errors.New("broker is gone"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := client.RequestStockSyncContext(ctx, "tok", 7); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("request returned after %s", elapsed)
+	}
+}
+
+func TestFailedNewReleasesResources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cwapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	transport := &flakyTransport{MemoryTransport: NewMemoryTransport(), down: 1}
+	client, err := NewClientWithTransport("login", transport, WithLogger(nil), WithDeadLetterFile(filepath.Join(dir, "dead.jsonl")))
+	if err == nil {
+		t.Fatal("New succeeded without broker")
+	}
+
+	if err := client.deadLetter.file.Close(); err == nil {
+		t.Fatal("dead letter file is left open")
+	}
+	if _, ok := <-client.Updates; ok {
+		t.Fatal("Updates channel is left open")
+	}
+	if err := client.Close(context.Background()); err != nil {
+		t.Fatalf("Close of failed client returned %v", err)
+	}
+}
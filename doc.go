@@ -94,6 +94,77 @@
 //		log.Println(string(b))
 //	}
 //
+// In real bot codes come from many users at once, AuthFlow tracks them for you and enforces expiry and attempt limits:
+//
+//	flow := cwapi.NewAuthFlow(client, 5*time.Minute, 3)
+//	flow.Start(ctx, userID)
+//	// later, when user sent the code
+//	status, err := flow.Submit(ctx, userID, code)
+//
+// Use New with options if you need custom broker URL, TLS config, buffer sizes, timeouts, logger or reconnection policy:
+//
+//	client, err := cwapi.New("login", "password",
+//		cwapi.WithServer("cw3"),
+//		cwapi.WithSyncTimeout(5*time.Second),
+//	)
+//
+// Sync methods wait for the response up to 10 seconds. If you want to control it yourself,
+// e.g. propagate deadline of incoming HTTP request, use SyncContext analogs:
+//
+//	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+//	defer cancel()
+//
+//	res, err := client.RequestStockSyncContext(ctx, token, userID)
+//	if err != nil {
+//		log.Println(err)
+//	}
+//
+// If server answers with result other than Ok, Sync methods return *APIError along with the response:
+//
+//	res, err := client.RequestStockSync(token, userID)
+//	if cwapi.IsForbidden(err) {
+//		e, _ := cwapi.AsAPIError(err)
+//		log.Println("need operation", e.RequiredOperation)
+//	}
+//
+// Escalate does the whole authAdditionalOperation dance for you and repeats the request,
+// you just provide the code user got from Chat Wars:
+//
+//	res, err := client.Escalate(ctx, token, userID, askCode, func(ctx context.Context) (*cwapi.Response, error) {
+//		return client.RequestStockSyncContext(ctx, token, userID)
+//	})
+//
+// Client can remember granted tokens for you. TokenStore keeps them along with additional operations
+// and drops ones server answered with InvalidToken:
+//
+//	store, err := cwapi.NewFileTokenStore("tokens.json")
+//	client, err := cwapi.New("login", "password", cwapi.WithTokenStore(store))
+//
+//	token, err := client.Token(userID)
+//	if err == cwapi.ErrNoToken {
+//		client.CreateAuthCodeSync(userID)
+//	}
+//
+// Payments tracks authorizePayment and pay of every payment, so the same transaction is never paid twice:
+//
+//	payments := cwapi.NewPayments(client)
+//	p, err := payments.Authorize(ctx, token, userID, 5)
+//	// later, when user sent confirmation code
+//	p, err = payments.Confirm(ctx, p.TransactionID, code)
+//
+// Set Ledger with WithLedger to record every pay and payout request and response on disk,
+// entries can be queried by user and date or exported to CSV:
+//
+//	ledger, err := cwapi.OpenLedger("ledger.jsonl")
+//	client, err := cwapi.New("login", "password", cwapi.WithLedger(ledger))
+//	ledger.ExportCSV(os.Stdout, cwapi.LedgerQuery{UserID: userID})
+//
+// Reconciler compares application balance from getInfo with the ledger and alerts you about discrepancies and low balance:
+//
+//	r := cwapi.NewReconciler(client, ledger, balance, time.Now())
+//	r.Alert = func(report cwapi.ReconcileReport) { log.Println(report) }
+//	go r.Run(ctx)
+//
 // Fan-out Exchange Routing Keys
 //
 // If you want to deal with routing keys, there are a bunch of methods:
@@ -121,6 +192,73 @@
 //		log.Println(string(b))
 //	}
 //
+// Any other queue of your application, even the one this library doesn't know yet, is available through Subscribe:
+//
+//	items, err := client.Subscribe(cwapi.Stream("new_exchange"), cwapi.SubscribeOptions{
+//		Decode: cwapi.DecodeJSON(MyItem{}),
+//	})
+//
+// Subscribe acks deliveries as soon as they are sent to the channel. Use SubscribeManual if you want
+// to ack them yourself after processing, so nothing is lost if your bot crashes:
+//
+//	deals, err := client.SubscribeManual(cwapi.StreamDeals, cwapi.SubscribeOptions{
+//		Decode:   cwapi.DecodeJSON(cwapi.Deal{}),
+//		Prefetch: 10,
+//	})
+//	for e := range deals {
+//		store(e.Value.(cwapi.Deal))
+//		e.Ack()
+//	}
+//
+// Actions this library doesn't wrap yet can be sent with Call, payload of the response is returned as is:
+//
+//	res, err := client.Call(ctx, "newAction", token, map[string]interface{}{"itemCode": "07"}, userID)
+//
+// Or register it once with RegisterAction and get typed response payload in Response.Decoded:
+//
+//	cwapi.RegisterAction(cwapi.ActionSpec{Name: "newAction", Request: ReqNewAction{}, Response: ResNewAction{}})
+//	res, err := client.Do(ctx, "newAction", token, ReqNewAction{UserID: userID}, userID)
+//
+// Reconnection
+//
+// Client watches its connection and reconnects with exponential backoff, every initialized stream
+// is resubscribed automatically. You can listen for connection state changes:
+//
+//	events := client.NotifyConnectionState(make(chan cwapi.ConnectionEvent, 10))
+//	for e := range events {
+//		log.Println(e.State, e.Attempt, e.Err)
+//	}
+//
+// Shutdown
+//
+// Close stops consumers, delivers what was already received and closes every stream channel,
+// so ranging over them ends gracefully:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//
+//	if err := client.Close(ctx); err != nil {
+//		log.Println(err)
+//	}
+//
+// Multiple accounts
+//
+// Pool manages clients of several applications, even on different servers:
+//
+//	pool := cwapi.NewPool()
+//	pool.Add(cwapi.Account{Name: "eu", User: "login", Password: "password", Server: "cw2"})
+//	pool.Add(cwapi.Account{Name: "ru", User: "login2", Password: "password2", Server: "cw3"})
+//	defer pool.Close(context.Background())
+//
+//	for u := range pool.MergeUpdates(100) {
+//		log.Println(u.Account, u.Response.Action)
+//	}
+//
+// Testing
+//
+// Client works on top of Transport interface. Use MemoryTransport with NewClientWithTransport
+// to test your bot without live Chat Wars broker, see MemoryTransport for example.
+//
 // Feedback
 //
 // If you have any questions, you can ask them in Chat Wars Development chat:
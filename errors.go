@@ -0,0 +1,96 @@
+package cwapi
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Returned by requests made after Close was called, pending Sync requests fail with it as well.
+var ErrClientClosed = errors.New("cwapi: client is closed")
+
+// Returned by Sync methods when server answered with result other than Ok.
+// Response is returned along with it, so you can inspect the payload as well.
+type APIError struct {
+	Result            ResultEnum
+	Action            string
+	UUID              string
+	UserID            int
+	RequiredOperation string
+}
+
+func newAPIError(res *Response, userID int) *APIError {
+	return &APIError{
+		Result:            res.GetResultEnum(),
+		Action:            res.Action,
+		UUID:              res.UUID,
+		UserID:            userID,
+		RequiredOperation: res.Payload.RequiredOperation,
+	}
+}
+
+func (e *APIError) Error() string {
+	if e.Result == Forbidden && e.RequiredOperation != "" {
+		return fmt.Sprintf("%s: %s, required operation: %s", e.Action, e.Result, e.RequiredOperation)
+	}
+	return fmt.Sprintf("%s: %s", e.Action, e.Result)
+}
+
+// Reports whether target is APIError with the same result, so errors.Is(err, cwapi.ErrForbidden) works.
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	if !ok {
+		return false
+	}
+	return t.Result == e.Result
+}
+
+// Sentinel values for every documented result, use them with errors.Is.
+var (
+	ErrBadAmount           = &APIError{Result: BadAmount}
+	ErrBadCurrency         = &APIError{Result: BadCurrency}
+	ErrBadFormat           = &APIError{Result: BadFormat}
+	ErrActionNotFound      = &APIError{Result: ActionNotFound}
+	ErrNoSuchUser          = &APIError{Result: NoSuchUser}
+	ErrNotRegistered       = &APIError{Result: NotRegistered}
+	ErrInvalidCode         = &APIError{Result: InvalidCode}
+	ErrNoSuchOperation     = &APIError{Result: NoSuchOperation}
+	ErrTryAgain            = &APIError{Result: TryAgain}
+	ErrAuthorizationFailed = &APIError{Result: AuthorizationFailed}
+	ErrInsufficientFunds   = &APIError{Result: InsufficientFunds}
+	ErrLevelIsLow          = &APIError{Result: LevelIsLow}
+	ErrNotInGuild          = &APIError{Result: NotInGuild}
+	ErrInvalidToken        = &APIError{Result: InvalidToken}
+	ErrForbidden           = &APIError{Result: Forbidden}
+	ErrUnknownResult       = &APIError{Result: UnknownResult}
+)
+
+// Returns APIError if err is one.
+func AsAPIError(err error) (*APIError, bool) {
+	e, ok := err.(*APIError)
+	return e, ok
+}
+
+// Reports whether token has no rights for the action, see APIError.RequiredOperation.
+func IsForbidden(err error) bool {
+	return isResult(err, Forbidden)
+}
+
+// Reports whether token is invalid or revoked.
+func IsInvalidToken(err error) bool {
+	return isResult(err, InvalidToken)
+}
+
+// Reports whether authorization code user entered is wrong.
+func IsInvalidCode(err error) bool {
+	return isResult(err, InvalidCode)
+}
+
+// Reports whether server asked to repeat the request.
+func IsRetryable(err error) bool {
+	return isResult(err, TryAgain)
+}
+
+func isResult(err error, result ResultEnum) bool {
+	e, ok := AsAPIError(err)
+	return ok && e.Result == result
+}
@@ -0,0 +1,56 @@
+package cwapi
+
+import (
+	"context"
+)
+
+// Returns code user received from Chat Wars after authAdditionalOperation request,
+// e.g. asks for it in your bot conversation. It should give up once ctx is done.
+type CodeProvider func(ctx context.Context, userID int, operation string) (string, error)
+
+// Calls fn and if server answers Forbidden, requests required operation from the user, waits for the code
+// from provider, grants it and calls fn once more:
+//
+//	res, err := client.Escalate(ctx, token, userID, askCode, func(ctx context.Context) (*cwapi.Response, error) {
+//		return client.RequestStockSyncContext(ctx, token, userID)
+//	})
+//
+// Errors of authAdditionalOperation, provider and grantAdditionalOperation are returned as is,
+// so ctx should be long enough for user to enter the code.
+func (c *Client) Escalate(ctx context.Context, token string, userID int, provider CodeProvider, fn func(ctx context.Context) (*Response, error)) (*Response, error) {
+	res, err := fn(ctx)
+
+	e, ok := AsAPIError(err)
+	if !ok || e.Result != Forbidden || e.RequiredOperation == "" {
+		return res, err
+	}
+
+	c.logger.Info("escalating token rights",
+		"action", e.Action,
+		"user_id", userID,
+		"operation", e.RequiredOperation,
+	)
+
+	if err := c.GrantOperation(ctx, token, userID, e.RequiredOperation, provider); err != nil {
+		return res, err
+	}
+
+	return fn(ctx)
+}
+
+// Requests additional operation from the user, waits for the code from provider and grants it.
+func (c *Client) GrantOperation(ctx context.Context, token string, userID int, operation string, provider CodeProvider) error {
+	auth, err := c.AuthAdditionalOperationSyncContext(ctx, token, operation, userID)
+	if err != nil {
+		return err
+	}
+
+	code, err := provider(ctx, userID, operation)
+	if err != nil {
+		return err
+	}
+
+	// requestId is UUID of authAdditionalOperation response
+	_, err = c.GrantAdditionalOperationSyncContext(ctx, token, auth.UUID, code, userID)
+	return err
+}
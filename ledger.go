@@ -0,0 +1,357 @@
+package cwapi
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LedgerEntryType string
+
+const (
+	// Request was sent to the server
+	LedgerRequest LedgerEntryType = "request"
+	// Server answered the request
+	LedgerResponse LedgerEntryType = "response"
+)
+
+// Request entries older than this are not expected to get the response anymore and are evicted.
+const ledgerPendingTTL = time.Hour
+
+// Actions which move money, they are recorded to the ledger.
+var ledgerActions = map[string]bool{
+	"pay":    true,
+	"payout": true,
+}
+
+// Single record of pay or payout request or response.
+type LedgerEntry struct {
+	Time          time.Time       `json:"time"`
+	Type          LedgerEntryType `json:"type"`
+	Action        string          `json:"action"`
+	TransactionID string          `json:"transactionId"`
+	UserID        int             `json:"userId,omitempty"`
+	Amount        map[string]int  `json:"amount,omitempty"`
+	Fee           map[string]int  `json:"fee,omitempty"`
+	Debit         map[string]int  `json:"debit,omitempty"`
+	// Empty for requests
+	Result string `json:"result,omitempty"`
+	// For responses, time the request was sent at if it was recorded
+	RequestedAt time.Time `json:"requestedAt"`
+}
+
+// Selects ledger entries, zero fields match everything.
+type LedgerQuery struct {
+	UserID int
+	// Inclusive
+	From time.Time
+	// Exclusive
+	To time.Time
+}
+
+func (q LedgerQuery) match(e *LedgerEntry) bool {
+	if q.UserID != 0 && e.UserID != q.UserID {
+		return false
+	}
+	if !q.From.IsZero() && e.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !e.Time.Before(q.To) {
+		return false
+	}
+	return true
+}
+
+// Durable log of money moved by pay and payout, one JSON object per line. Every entry is synced to disk
+// before Append returns. Client appends entries itself once ledger is set with WithLedger:
+//
+//	ledger, err := cwapi.OpenLedger("ledger.jsonl")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer ledger.Close()
+//
+//	client, err := cwapi.New("login", "password", cwapi.WithLedger(ledger))
+//
+//	entries, err := ledger.Entries(cwapi.LedgerQuery{UserID: userID, From: time.Now().AddDate(0, -1, 0)})
+type Ledger struct {
+	mu   sync.Mutex
+	file *os.File
+	// request entries waiting for the response, keyed by correlation ID
+	pending map[string]*pendingEntry
+	// the same entries by action and userID in FIFO order, for responses without correlation ID
+	byRoute map[route][]*pendingEntry
+}
+
+// Request entry waiting for the response
+type pendingEntry struct {
+	correlationID string
+	route         route
+	entry         LedgerEntry
+}
+
+// Opens ledger file, entries are appended to existing ones.
+func OpenLedger(path string) (*Ledger, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Ledger{
+		file:    file,
+		pending: make(map[string]*pendingEntry),
+		byRoute: make(map[route][]*pendingEntry),
+	}, nil
+}
+
+// Appends entry to the file.
+func (l *Ledger) Append(e LedgerEntry) error {
+	line, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// Returns entries matching query in order they were appended. Requests sent by async Pay and Payout
+// are recorded without user, it's taken from the response with the same transaction ID.
+func (l *Ledger) Entries(q LedgerQuery) ([]LedgerEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var all []LedgerEntry
+	users := make(map[string]int)
+	scanner := bufio.NewScanner(l.file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e LedgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, err
+		}
+		if e.UserID != 0 && e.TransactionID != "" {
+			users[e.TransactionID] = e.UserID
+		}
+		all = append(all, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var entries []LedgerEntry
+	for _, e := range all {
+		if e.UserID == 0 {
+			e.UserID = users[e.TransactionID]
+		}
+		if q.match(&e) {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
+
+// Writes entries matching query as CSV with header row. Amount, fee and debit are written
+// as "currency:value" pairs separated by semicolon, e.g. "gold:100;pouches:1".
+func (l *Ledger) ExportCSV(w io.Writer, q LedgerQuery) error {
+	entries, err := l.Entries(q)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"time", "type", "action", "transaction_id", "user_id", "amount", "fee", "debit", "result", "requested_at"})
+	for _, e := range entries {
+		requestedAt := ""
+		if !e.RequestedAt.IsZero() {
+			requestedAt = e.RequestedAt.Format(time.RFC3339Nano)
+		}
+
+		writer.Write([]string{
+			e.Time.Format(time.RFC3339Nano),
+			string(e.Type),
+			e.Action,
+			e.TransactionID,
+			strconv.Itoa(e.UserID),
+			formatAmount(e.Amount),
+			formatAmount(e.Fee),
+			formatAmount(e.Debit),
+			e.Result,
+			requestedAt,
+		})
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// Closes ledger file.
+func (l *Ledger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
+
+// Keeps request entry until its response is recorded, entries past ledgerPendingTTL are evicted.
+func (l *Ledger) addPending(correlationID string, e LedgerEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	deadline := e.Time.Add(-ledgerPendingTTL)
+	for _, p := range l.pending {
+		if p.entry.Time.Before(deadline) {
+			l.removePendingLocked(p)
+		}
+	}
+
+	p := &pendingEntry{correlationID, route{e.Action, e.UserID}, e}
+	l.pending[correlationID] = p
+	l.byRoute[p.route] = append(l.byRoute[p.route], p)
+}
+
+// Returns request entry of the response and forgets it. Like waiters, responses are matched by correlation ID,
+// ones without it by action and userID in FIFO order. Requests sent without userID, e.g. by Pay,
+// are matched after requests of the user.
+func (l *Ledger) takePending(correlationID string, fallback route) (LedgerEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	p, found := l.pending[correlationID]
+	if !found {
+		if correlationID != "" {
+			return LedgerEntry{}, false
+		}
+
+		queue := l.byRoute[fallback]
+		if len(queue) == 0 {
+			queue = l.byRoute[route{fallback.action, 0}]
+		}
+		if len(queue) == 0 {
+			return LedgerEntry{}, false
+		}
+		p = queue[0]
+	}
+
+	l.removePendingLocked(p)
+	return p.entry, true
+}
+
+func (l *Ledger) removePendingLocked(p *pendingEntry) {
+	delete(l.pending, p.correlationID)
+
+	queue := l.byRoute[p.route]
+	for i := range queue {
+		if queue[i] == p {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) == 0 {
+		delete(l.byRoute, p.route)
+	} else {
+		l.byRoute[p.route] = queue
+	}
+}
+
+// Formats amount with sorted currencies, e.g. "gold:100;pouches:1".
+func formatAmount(amount map[string]int) string {
+	currencies := make([]string, 0, len(amount))
+	for currency := range amount {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	pairs := make([]string, len(currencies))
+	for i, currency := range currencies {
+		pairs[i] = fmt.Sprintf("%s:%d", currency, amount[currency])
+	}
+	return strings.Join(pairs, ";")
+}
+
+// Records pay and payout request before it's published.
+func (c *Client) recordRequest(req []byte, correlationID string, userID int) {
+	if c.ledger == nil {
+		return
+	}
+
+	var r struct {
+		Action  string `json:"action"`
+		Payload struct {
+			TransactionID string         `json:"transactionId"`
+			Amount        map[string]int `json:"amount"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(req, &r); err != nil || !ledgerActions[r.Action] {
+		return
+	}
+
+	e := LedgerEntry{
+		Time:          time.Now(),
+		Type:          LedgerRequest,
+		Action:        r.Action,
+		TransactionID: r.Payload.TransactionID,
+		UserID:        userID,
+		Amount:        r.Payload.Amount,
+	}
+
+	c.ledger.addPending(correlationID, e)
+
+	if err := c.ledger.Append(e); err != nil {
+		c.logger.Error("cannot write ledger", "action", e.Action, "transaction_id", e.TransactionID, "error", err)
+	}
+}
+
+// Records pay and payout response, request details are taken from its entry.
+func (c *Client) recordResponse(res *Response, correlationID string, userID int) {
+	if c.ledger == nil || !ledgerActions[res.Action] {
+		return
+	}
+
+	e := LedgerEntry{
+		Time:   time.Now(),
+		Type:   LedgerResponse,
+		Action: res.Action,
+		UserID: userID,
+		Result: res.Result,
+	}
+
+	if req, found := c.ledger.takePending(correlationID, route{res.Action, userID}); found {
+		e.TransactionID = req.TransactionID
+		e.Amount = req.Amount
+		e.RequestedAt = req.Time
+		if e.UserID == 0 {
+			e.UserID = req.UserID
+		}
+	}
+
+	if pay := res.Payload.ResPay; pay != nil {
+		e.Fee = pay.Fee
+		e.Debit = pay.Debit
+		if pay.TransactionId != "" {
+			e.TransactionID = pay.TransactionId
+		}
+	}
+
+	if err := c.ledger.Append(e); err != nil {
+		c.logger.Error("cannot write ledger", "action", e.Action, "transaction_id", e.TransactionID, "error", err)
+	}
+}
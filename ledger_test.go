@@ -0,0 +1,110 @@
+package cwapi
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Opens ledger in temporary directory, returned func removes it.
+func openTestLedger(t *testing.T) (*Ledger, func()) {
+	dir, err := ioutil.TempDir("", "cwapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ledger, err := OpenLedger(filepath.Join(dir, "ledger.jsonl"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return ledger, func() {
+		ledger.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestLedgerMatchesResponseWithoutCorrelationID(t *testing.T) {
+	ledger, cleanup := openTestLedger(t)
+	defer cleanup()
+
+	client, transport := newTestClient(t, WithLedger(ledger))
+	defer closeTestClient(client)
+
+	go func() {
+		<-transport.Requests()
+		transport.DeliverRaw("login_i", "", []byte(`{"action":"payout","result":"Ok","payload":{"userId":7}}`))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if _, err := client.PayoutSyncContext(ctx, "tok", "tx1", 3, "prize", 7); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ledger.Entries(LedgerQuery{UserID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected request and response entries, got %+v", entries)
+	}
+	if res := entries[1]; res.Type != LedgerResponse || res.TransactionID != "tx1" || res.Amount["pouches"] != 3 || res.RequestedAt.IsZero() {
+		t.Fatalf("response entry is not paired with the request: %+v", res)
+	}
+}
+
+func TestLedgerEvictsStalePending(t *testing.T) {
+	ledger, cleanup := openTestLedger(t)
+	defer cleanup()
+
+	ledger.addPending("old", LedgerEntry{Time: time.Now().Add(-2 * ledgerPendingTTL), Action: "pay", UserID: 7})
+	ledger.addPending("new", LedgerEntry{Time: time.Now(), Action: "pay", UserID: 7, TransactionID: "tx2"})
+
+	if _, found := ledger.takePending("old", route{}); found {
+		t.Fatal("stale entry was not evicted")
+	}
+	if e, found := ledger.takePending("", route{"pay", 7}); !found || e.TransactionID != "tx2" {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+	if len(ledger.pending) != 0 || len(ledger.byRoute) != 0 {
+		t.Fatal("pending entries left")
+	}
+}
+
+func TestLedgerQueryByUserIncludesAsyncRequests(t *testing.T) {
+	ledger, cleanup := openTestLedger(t)
+	defer cleanup()
+
+	client, transport := newTestClient(t, WithLedger(ledger))
+	defer closeTestClient(client)
+
+	if err := client.Payout("tok", "tx1", 3, "prize"); err != nil {
+		t.Fatal(err)
+	}
+	<-transport.Requests()
+	transport.DeliverRaw("login_i", "", []byte(`{"action":"payout","result":"Ok","payload":{"userId":7,"transactionId":"tx1"}}`))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entries, err := ledger.Entries(LedgerQuery{UserID: 7})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) == 2 {
+			if entries[0].Type != LedgerRequest || entries[0].UserID != 7 {
+				t.Fatalf("unexpected request entry %+v", entries[0])
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected request and response entries, got %+v", entries)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,75 @@
+package cwapi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Structured logger used by Client. Args are alternating keys and values,
+// the same way log/slog does, so *slog.Logger can be passed to WithLogger as is.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// Adapts standard logger, messages are printed as "LEVEL msg key=value ...".
+// Debug messages are skipped unless debug is set.
+func NewStdLogger(logger *log.Logger, debug bool) Logger {
+	return &stdLogger{logger, debug}
+}
+
+// Logger which drops everything.
+var DiscardLogger Logger = discardLogger{}
+
+var defaultLogger = NewStdLogger(log.New(os.Stderr, "", log.LstdFlags), false)
+
+type stdLogger struct {
+	logger *log.Logger
+	debug  bool
+}
+
+func (l *stdLogger) Debug(msg string, args ...interface{}) {
+	if l.debug {
+		l.print("DEBUG", msg, args)
+	}
+}
+
+func (l *stdLogger) Info(msg string, args ...interface{}) {
+	l.print("INFO", msg, args)
+}
+
+func (l *stdLogger) Warn(msg string, args ...interface{}) {
+	l.print("WARN", msg, args)
+}
+
+func (l *stdLogger) Error(msg string, args ...interface{}) {
+	l.print("ERROR", msg, args)
+}
+
+func (l *stdLogger) print(level string, msg string, args []interface{}) {
+	var b strings.Builder
+	b.WriteString(level)
+	b.WriteString(" ")
+	b.WriteString(msg)
+
+	for i := 0; i < len(args); i += 2 {
+		if i+1 < len(args) {
+			fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+		} else {
+			fmt.Fprintf(&b, " !BADKEY=%v", args[i])
+		}
+	}
+
+	l.logger.Println(b.String())
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Debug(msg string, args ...interface{}) {}
+func (discardLogger) Info(msg string, args ...interface{})  {}
+func (discardLogger) Warn(msg string, args ...interface{})  {}
+func (discardLogger) Error(msg string, args ...interface{}) {}
@@ -1,11 +1,10 @@
 package cwapi
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/streadway/amqp"
-	"log"
-	"strings"
+	"time"
 )
 
 func (res *Response) UnmarshalJSON(b []byte) error {
@@ -29,205 +28,83 @@ func (res *Response) UnmarshalJSON(b []byte) error {
 	res.Payload.RequiredOperation = payload.RequiredOperation
 	res.Payload.Token = payload.Token
 	res.Action = temp.Action
+	res.RawPayload = temp.Payload
 
-	switch temp.Action {
-	case "createAuthCode":
-		var payload ResCreateAuthCode
-		if err := json.Unmarshal(temp.Payload, &payload); err != nil {
-			return err
-		}
-		res.Payload.ResCreateAuthCode = &payload
-	case "grantToken":
-		var payload ResGrantToken
-		if err := json.Unmarshal(temp.Payload, &payload); err != nil {
-			return err
-		}
-		res.Payload.ResGrantToken = &payload
-	case "authAdditionalOperation":
-		var payload ResAuthAdditionalOperation
-		if err := json.Unmarshal(temp.Payload, &payload); err != nil {
-			return err
-		}
-		res.Payload.ResAuthAdditionalOperation = &payload
-	case "grantAdditionalOperation":
-		var payload ResGrantAdditionalOperation
-		if err := json.Unmarshal(temp.Payload, &payload); err != nil {
-			return err
-		}
-		res.Payload.ResGrantAdditionalOperation = &payload
-	case "authorizePayment":
-		var payload ResAuthorizePayment
-		if err := json.Unmarshal(temp.Payload, &payload); err != nil {
-			return err
-		}
-		res.Payload.ResAuthorizePayment = &payload
-	case "pay":
-		var payload ResPay
-		if err := json.Unmarshal(temp.Payload, &payload); err != nil {
-			return err
-		}
-		res.Payload.ResPay = &payload
-	case "payout":
-		var payload ResPayout
-		if err := json.Unmarshal(temp.Payload, &payload); err != nil {
-			return err
-		}
-		res.Payload.ResPayout = &payload
-	case "getInfo":
-		var payload ResGetInfo
-		if err := json.Unmarshal(temp.Payload, &payload); err != nil {
-			return err
-		}
-		res.Payload.ResGetInfo = &payload
-	case "viewCraftbook":
-		var payload ResViewCraftbook
-		if err := json.Unmarshal(temp.Payload, &payload); err != nil {
-			return err
-		}
-		res.Payload.ResViewCraftbook = &payload
-	case "requestProfile":
-		var payload ResRequestProfile
-		if err := json.Unmarshal(temp.Payload, &payload); err != nil {
-			return err
-		}
-		res.Payload.ResRequestProfile = &payload
-	case "requestBasicInfo":
-		var payload ResRequestBasicInfo
-		if err := json.Unmarshal(temp.Payload, &payload); err != nil {
-			return err
-		}
-		res.Payload.ResRequestBasicInfo = &payload
-	case "requestGearInfo":
-		var payload ResRequestGearInfo
-		if err := json.Unmarshal(temp.Payload, &payload); err != nil {
-			return err
-		}
-		res.Payload.ResRequestGearInfo = &payload
-	case "requestStock":
-		var payload ResRequestStock
-		if err := json.Unmarshal(temp.Payload, &payload); err != nil {
-			return err
-		}
-		res.Payload.ResRequestStock = &payload
-	case "guildInfo":
-		var payload ResGuildInfo
-		if err := json.Unmarshal(temp.Payload, &payload); err != nil {
+	if spec, found := LookupAction(ActionEnum(temp.Action)); found {
+		decoded, err := spec.decode(temp.Payload)
+		if err != nil {
 			return err
 		}
-		res.Payload.ResGuildInfo = &payload
-	case "wantToBuy":
-		var payload ResWantToBuy
-		if err := json.Unmarshal(temp.Payload, &payload); err != nil {
-			return err
+		if decoded != nil {
+			res.Decoded = decoded
+			res.Payload.set(decoded)
 		}
-		res.Payload.ResWantToBuy = &payload
-	default:
-		res.Action = "unknownMethod"
 	}
 
 	return nil
 }
 
-func (payload *reqPayload) MarshalJSON() ([]byte, error) {
-	if payload.reqCreateAuthCode != nil {
-		return json.Marshal(payload.reqCreateAuthCode)
-	}
-	if payload.reqGrantToken != nil {
-		return json.Marshal(payload.reqGrantToken)
-	}
-	if payload.reqAuthAdditionalOperation != nil {
-		return json.Marshal(payload.reqAuthAdditionalOperation)
-	}
-	if payload.reqGrantAdditionalOperation != nil {
-		return json.Marshal(payload.reqGrantAdditionalOperation)
-	}
-	if payload.reqAuthorizePayment != nil {
-		return json.Marshal(payload.reqAuthorizePayment)
-	}
-	if payload.reqPay != nil {
-		return json.Marshal(payload.reqPay)
-	}
-	if payload.reqPayout != nil {
-		return json.Marshal(payload.reqPayout)
-	}
-	if payload.reqWantToBuy != nil {
-		return json.Marshal(payload.reqWantToBuy)
-	}
-
-	return json.Marshal(nil)
-}
-
 // Create new client, you can set server optional param, defaults to Chat Wars 2 server (or EU), accepts those variants:
 // cw2, eu, cw3, ru
+//
+// Use New if you need more options.
 func NewClient(user string, password string, server ...string) (*Client, error) {
-	rabbitUrl := fmt.Sprintf(CW2, user, password)
-
+	var opts []Option
 	if len(server) > 0 {
-		if strings.ToLower(server[0]) == "cw2" || strings.ToLower(server[0]) == "eu" {
-			rabbitUrl = fmt.Sprintf(CW2, user, password)
-		} else if strings.ToLower(server[0]) == "cw3" || strings.ToLower(server[0]) == "ru" {
-			rabbitUrl = fmt.Sprintf(CW3, user, password)
-		}
-	}
-
-	client := Client{
-		User:      user,
-		Password:  password,
-		RabbitUrl: rabbitUrl,
+		opts = append(opts, WithServer(server[0]))
 	}
 
-	client.Updates = make(chan Response, 100)
-	err := client.reconnect()
-	return &client, err
+	return New(user, password, opts...)
 }
 
-func (c *Client) reStartConsumers() error {
-	var err error
+// Create new client on top of custom transport, e.g. MemoryTransport in tests.
+func NewClientWithTransport(user string, transport Transport, opts ...Option) (*Client, error) {
+	return New(user, "", append(opts, WithTransport(transport))...)
+}
 
-	if c.Updates != nil {
-		err := c.startUpdateConsumer()
-		if err != nil {
-			return err
-		}
+func (c *Client) start() error {
+	c.reconnected = make(chan struct{})
+	c.done = make(chan struct{})
+	c.abort = make(chan struct{})
+
+	c.Updates = make(chan Response, c.bufferSizes[StreamUpdates])
+	updates, err := c.newSink(StreamUpdates, c.Updates, func(body []byte) (interface{}, error) {
+		var res Response
+		err := json.Unmarshal(body, &res)
+		return res, err
+	}, c.overflowPolicies[StreamUpdates])
+	if err != nil {
+		return err
 	}
-
-	if c.Deals != nil {
-		err := c.startDealsConsumer()
-		if err != nil {
-			return err
-		}
+	c.updates = updates
+	if err := c.startSink(updates); err != nil {
+		return err
 	}
 
-	if c.Duels != nil {
-		err := c.startDuelsConsumer()
-		if err != nil {
-			return err
-		}
+	err = c.connect()
+	if err != nil {
+		return err
 	}
 
-	if c.Offers != nil {
-		err := c.startOffersConsumer()
-		if err != nil {
-			return err
-		}
-	}
+	c.setState(StateConnected, 0, nil)
+	go c.supervise()
 
-	if c.SexDigest != nil {
-		err := c.startSexDigestConsumer()
-		if err != nil {
-			return err
-		}
-	}
+	return nil
+}
 
-	if c.YellowPages != nil {
-		err := c.startYellowPages()
+func (c *Client) reStartConsumers() error {
+	c.subscribeMu.Lock()
+	defer c.subscribeMu.Unlock()
+
+	if c.Updates != nil {
+		err := c.startUpdateConsumer()
 		if err != nil {
 			return err
 		}
 	}
 
-	if c.AuctionDigest != nil {
-		err = c.startAuctionDigestConsumer()
+	for _, s := range c.activeSubscriptions() {
+		err := c.startSubscription(s)
 		if err != nil {
 			return err
 		}
@@ -236,177 +113,364 @@ func (c *Client) reStartConsumers() error {
 	return nil
 }
 
-func (c *Client) reconnect() error {
-	// Open new
-	conn, err := amqp.Dial(c.RabbitUrl)
-	if err != nil {
-		return err
-	}
+// Returns snapshot of subscriptions.
+func (c *Client) activeSubscriptions() []*subscription {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	chForPublish, err := conn.Channel()
-	if err != nil {
-		return err
+	subscriptions := make([]*subscription, 0, len(c.subscriptions))
+	for _, s := range c.subscriptions {
+		subscriptions = append(subscriptions, s)
 	}
+	return subscriptions
+}
 
-	chForUpdates, err := conn.Channel()
+// Opens new session, replaces old one and restarts consumers.
+func (c *Client) connect() error {
+	// Open new
+	session, err := c.transport.Dial()
 	if err != nil {
 		return err
 	}
 
-	// force close old channels and connection to close old consumers
-	if c.channelForUpdates != nil {
-		c.channelForUpdates.Close()
-	}
-	if c.channelForPublish != nil {
-		c.channelForPublish.Close()
+	c.mu.Lock()
+	// client was closed while we were dialing
+	if c.closing {
+		c.mu.Unlock()
+		session.Close()
+		return ErrClientClosed
 	}
-	if c.connection != nil {
-		c.connection.Close()
+
+	// force close old session to close old consumers
+	if c.session != nil {
+		c.session.Close()
 	}
 	// Reassign it and restart consumers
-	c.connection = conn
-	c.channelForPublish = chForPublish
-	c.channelForUpdates = chForUpdates
+	c.session = session
 
-	err = c.reStartConsumers()
-	if err != nil {
-		return err
-	}
+	// wake up publishers waiting for new session
+	close(c.reconnected)
+	c.reconnected = make(chan struct{})
+	c.mu.Unlock()
 
-	go func() {
-		// Waits here for the channel to be closed
-		log.Printf("closing: %s", <-c.connection.NotifyClose(make(chan *amqp.Error)))
-		err = c.reconnect()
-		if err != nil {
-			log.Println("reconnect unsuccessful")
-		} else {
-			log.Println("reconnect successful")
-		}
-	}()
+	return c.reStartConsumers()
+}
 
-	return nil
+// Returns current session.
+func (c *Client) currentSession() Session {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.session
 }
 
 // Start consumer for base events
 func (c *Client) startUpdateConsumer() error {
-	updates, err := c.channelForUpdates.Consume(
-		fmt.Sprintf("%s_i", c.User),
-		"",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+	updates, err := c.currentSession().Consume(fmt.Sprintf("%s_i", c.User), true, 0)
 	if err != nil {
 		return err
 	}
 
-	go func() {
+	return c.goConsume(func() {
 		for update := range updates {
 			if update.RoutingKey == fmt.Sprintf("%s_i", c.User) {
 				var res Response
 				err := json.Unmarshal(update.Body, &res)
 				if err != nil {
-					log.Println(err)
+					c.handlePoison(StreamUpdates, update.Body, err)
+					continue
 				}
 
 				var userID int
-
-				switch res.Action {
-				case "createAuthCode":
-					userID = res.Payload.ResCreateAuthCode.UserID
-				case "grantToken":
-					userID = res.Payload.ResGrantToken.UserID
-				case "authAdditionalOperation":
-					userID = res.Payload.ResAuthAdditionalOperation.UserID
-				case "grantAdditionalOperation":
-					userID = res.Payload.ResGrantAdditionalOperation.UserID
-				case "authorizePayment":
-					userID = res.Payload.ResAuthorizePayment.UserID
-				case "pay":
-					userID = res.Payload.ResPay.UserID
-				case "payout":
-					userID = res.Payload.ResPayout.UserID
-				case "viewCraftbook":
-					userID = res.Payload.ResViewCraftbook.UserID
-				case "requestProfile":
-					userID = res.Payload.ResRequestProfile.UserID
-				case "requestBasicInfo":
-					userID = res.Payload.ResRequestBasicInfo.UserID
-				case "requestGearInfo":
-					userID = res.Payload.ResRequestGearInfo.UserID
-				case "requestStock":
-					userID = res.Payload.ResRequestStock.UserID
-				case "guildInfo":
-					userID = res.Payload.ResGuildInfo.UserID
-				case "wantToBuy":
-					userID = res.Payload.ResWantToBuy.UserID
+				if spec, found := LookupAction(ActionEnum(res.Action)); found {
+					userID = spec.userID(&res)
+				} else {
+					// made by Call
+					userID = payloadUserID(res.RawPayload)
 				}
 
-				// trying to load update with this salt
-				if waiter, found := c.waiters.Load(userID); found {
-					// found? send it to waiter channel
-					waiter.(chan Response) <- res
-
-					// trying to prevent memory leak
-					close(waiter.(chan Response))
+				c.logger.Debug("response received",
+					"stream", StreamUpdates,
+					"action", res.Action,
+					"user_id", userID,
+					"delivery_tag", update.DeliveryTag,
+				)
+
+				c.trackToken(&res, userID)
+				c.recordResponse(&res, update.CorrelationID, userID)
+
+				// trying to find Sync request waiting for this response
+				c.waiters.resolve(update.CorrelationID, route{res.Action, userID}, reply{res, update.Body})
+
+				// router replaces Updates channel
+				if c.router != nil {
+					if err := c.router.Dispatch(&res); err != nil {
+						c.logger.Error("handler failed",
+							"stream", StreamUpdates,
+							"action", res.Action,
+							"user_id", userID,
+							"error", err,
+						)
+					}
+					continue
 				}
 
-				c.Updates <- res
+				if !c.updates.put(res, update.Body, c.abort) {
+					c.logger.Warn("response dropped, client is closing",
+						"stream", StreamUpdates,
+						"action", res.Action,
+						"user_id", userID,
+					)
+				}
 			}
 		}
+	})
+}
+
+// Runs consumer goroutine, so Close can wait for it. Returns ErrClientClosed if client is closing.
+func (c *Client) goConsume(consume func()) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closing {
+		return ErrClientClosed
+	}
+
+	c.consumers.Add(1)
+	go func() {
+		defer c.consumers.Done()
+		consume()
 	}()
+
 	return nil
 }
 
-// Close connection and active channel
+// Close connection and active channel.
+// Same as Close with sync timeout as deadline, kept for compatibility. Deliveries nobody
+// has read by then are requeued, so it never hangs if application stopped reading the streams.
 func (c *Client) CloseConnection() error {
-	close(c.Updates)
-	close(c.Deals)
-	close(c.Duels)
-	close(c.Offers)
-	close(c.SexDigest)
-	close(c.YellowPages)
-	close(c.AuctionDigest)
-
-	if err := c.channelForUpdates.Close(); err != nil {
-		return err
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	return c.Close(ctx)
+}
+
+// Gracefully closes client. It stops consumers, waits until already received deliveries
+// are sent to the stream channels and acknowledged, then closes those channels and connection.
+// Pending Sync requests fail with ErrClientClosed right away.
+//
+// If ctx is done earlier, deliveries nobody has read are requeued and ctx error is returned.
+// Close is idempotent and safe for concurrent use, every call returns the same result.
+func (c *Client) Close(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		c.closeErr = c.shutdown(ctx)
+	})
+	return c.closeErr
+}
+
+func (c *Client) shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	c.closing = true
+	c.mu.Unlock()
+
+	// stop supervisor and fail pending Sync requests
+	close(c.done)
+	c.setState(StateClosed, 0, nil)
+
+	// nil if client has never connected, streams and files are closed anyway
+	session := c.currentSession()
+	if session != nil {
+		if err := session.Cancel(); err != nil {
+			c.logger.Warn("cannot cancel consumers", "error", err)
+		}
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		c.consumers.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+		// unblock consumers, so they requeue the rest
+		close(c.abort)
+	}
+
+	var closeErr error
+	if session != nil {
+		closeErr = session.Close()
+	}
+	<-drained
+
+	// there are no senders anymore, so it's safe to close streams
+	if c.Updates != nil {
+		close(c.Updates)
+	}
+	for _, s := range c.activeSubscriptions() {
+		if s.close != nil {
+			s.close()
+		}
 	}
-	if err := c.channelForPublish.Close(); err != nil {
+
+	c.mu.Lock()
+	if c.DecodeErrors != nil {
+		close(c.DecodeErrors)
+	}
+	c.mu.Unlock()
+
+	if c.deadLetter != nil {
+		if err := c.deadLetter.close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+
+	if err != nil {
 		return err
 	}
-	if err := c.connection.Close(); err != nil {
+	return closeErr
+}
+
+func (c *Client) makeRequest(req []byte) error {
+	err := c.publishRecorded(req, 0)
+	if err == nil || !c.retryPolicy.Async || !c.retryPolicy.allows(req) {
 		return err
 	}
 
-	return nil
+	for attempt := 1; c.retryPolicy.shouldRetry(attempt, err); attempt++ {
+		c.logger.Warn("retrying request", "attempt", attempt, "error", err)
+		time.Sleep(c.retryPolicy.backoff(attempt))
+
+		err = c.publishRecorded(req, 0)
+	}
+
+	return err
 }
 
-func (c *Client) makeRequest(req []byte) (err error) {
-	err = c.channelForPublish.Publish(
-		fmt.Sprintf("%s_ex", c.User),
-		fmt.Sprintf("%s_o", c.User),
-		true,
-		false,
-		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        req,
-		},
-	)
-	// If channel closed
-	if err != nil && err.(*amqp.Error).Code == 504 {
-		err = c.reconnect()
-		if err != nil {
-			return err
+// Publishes async request with new correlation ID, recording it to the ledger.
+// There is no caller's deadline, so reconnection and confirmation are awaited for sync timeout at most.
+func (c *Client) publishRecorded(req []byte, userID int) error {
+	correlationID := newCorrelationID()
+	c.recordRequest(req, correlationID, userID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	err := c.publish(ctx, req, correlationID)
+	if err == context.DeadlineExceeded && c.State() != StateConnected {
+		// timed out waiting for reconnection, not for confirmation of possibly delivered request
+		return ErrClosed
+	}
+	return err
+}
+
+// Publishes request with correlation ID, so its response can be matched.
+// If session is closed, it waits for reconnection until ctx is done.
+func (c *Client) publish(ctx context.Context, req []byte, correlationID string) error {
+	select {
+	case <-c.done:
+		return ErrClientClosed
+	default:
+	}
+
+	c.mu.RLock()
+	session := c.session
+	reconnected := c.reconnected
+	c.mu.RUnlock()
+
+	msg := Publishing{
+		Exchange:      fmt.Sprintf("%s_ex", c.User),
+		RoutingKey:    fmt.Sprintf("%s_o", c.User),
+		CorrelationID: correlationID,
+		Body:          req,
+	}
+
+	err := session.Publish(ctx, msg)
+	// If session closed
+	if err == ErrClosed {
+		// wait until supervisor reconnects
+		select {
+		case <-reconnected:
+		case <-c.done:
+			return ErrClientClosed
+		case <-ctx.Done():
+			return ctx.Err()
 		}
+
 		// And try again
-		if err := c.makeRequest(req); err != nil {
-			return err
+		return c.currentSession().Publish(ctx, msg)
+	}
+
+	return err
+}
+
+// Publishes request and waits for the response until ctx is done, repeats it according to retry policy.
+func (c *Client) makeSyncRequest(ctx context.Context, action string, req []byte, userID int) (*Response, error) {
+	rep, err := c.roundTrip(ctx, action, req, userID)
+	if rep == nil {
+		return nil, err
+	}
+	return &rep.response, err
+}
+
+// Same as makeSyncRequest, but returns raw body of the response as well.
+func (c *Client) roundTrip(ctx context.Context, action string, req []byte, userID int) (*reply, error) {
+	rep, err := c.roundTripOnce(ctx, action, req, userID)
+	if err == nil || !c.retryPolicy.allows(req) {
+		return rep, err
+	}
+
+	for attempt := 1; c.retryPolicy.shouldRetry(attempt, err); attempt++ {
+		c.logger.Warn("retrying request",
+			"action", action,
+			"user_id", userID,
+			"attempt", attempt,
+			"error", err,
+		)
+
+		select {
+		case <-ctx.Done():
+			return rep, err
+		case <-time.After(c.retryPolicy.backoff(attempt)):
 		}
-	} else if err != nil {
-		return err
+
+		rep, err = c.roundTripOnce(ctx, action, req, userID)
 	}
 
-	return nil
+	return rep, err
+}
+
+func (c *Client) roundTripOnce(ctx context.Context, action string, req []byte, userID int) (*reply, error) {
+	// Register waiter before publishing, otherwise fast response could be missed
+	waiter := c.waiters.add(action, userID)
+	c.recordRequest(req, waiter.correlationID, userID)
+
+	if err := c.publish(ctx, req, waiter.correlationID); err != nil {
+		c.waiters.remove(waiter)
+		return nil, err
+	}
+
+	select {
+	// wait response from main loop in startUpdateConsumer()
+	case rep := <-waiter.response:
+		if rep.response.GetResultEnum() != Ok {
+			if rep.response.GetResultEnum() == InvalidToken {
+				// payload may have no userId, so consumer couldn't do that
+				c.invalidateToken(userID)
+			}
+			return &rep, newAPIError(&rep.response, userID)
+		}
+		return &rep, nil
+	// or cancellation
+	case <-ctx.Done():
+		c.waiters.remove(waiter)
+		return nil, ctx.Err()
+	// or client closing
+	case <-c.done:
+		c.waiters.remove(waiter)
+		return nil, ErrClientClosed
+	}
 }
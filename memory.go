@@ -0,0 +1,244 @@
+package cwapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// In-memory transport for tests, no broker needed.
+//
+// Every request made by Client appears in Requests channel, you can answer it with Reply
+// and push public exchange messages with Deliver:
+//
+//	transport := cwapi.NewMemoryTransport()
+//	client, err := cwapi.NewClientWithTransport("login", transport)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	go func() {
+//		req := <-transport.Requests()
+//		transport.Reply("login", req, cwapi.Ok, cwapi.ResRequestStock{
+//			Stock:  map[string]int{"01": 5},
+//			UserID: 123456,
+//		})
+//	}()
+//
+//	res, err := client.RequestStockSync("token", 123456)
+type MemoryTransport struct {
+	mu       sync.Mutex
+	queues   map[string]chan Delivery
+	requests chan Publishing
+	session  *memorySession
+	tag      uint64
+}
+
+// Creates new in-memory transport. Every queue and Requests channel buffer up to 1000 messages.
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{
+		queues:   make(map[string]chan Delivery),
+		requests: make(chan Publishing, 1000),
+	}
+}
+
+func (t *MemoryTransport) Dial() (Session, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.session = &memorySession{
+		transport: t,
+		closed:    make(chan error, 1),
+		done:      make(chan struct{}),
+		cancelled: make(chan struct{}),
+	}
+
+	return t.session, nil
+}
+
+// Returns requests published by Client.
+func (t *MemoryTransport) Requests() <-chan Publishing {
+	return t.requests
+}
+
+// Puts raw message to the queue, e.g. "login_deals".
+func (t *MemoryTransport) DeliverRaw(queue string, correlationID string, body []byte) {
+	t.mu.Lock()
+	t.tag++
+	d := Delivery{
+		Acknowledger:  memoryAcknowledger{t, queue, correlationID, body},
+		RoutingKey:    queue,
+		CorrelationID: correlationID,
+		DeliveryTag:   t.tag,
+		Body:          body,
+	}
+	q := t.queue(queue)
+	t.mu.Unlock()
+
+	q <- d
+}
+
+// Marshals v and puts it to the queue, e.g. Deal to "login_deals" or []YellowPage to "login_yellow_pages".
+func (t *MemoryTransport) Deliver(queue string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	t.DeliverRaw(queue, "", body)
+	return nil
+}
+
+// Answers request with given result and payload, response is put to user's "_i" queue.
+func (t *MemoryTransport) Reply(user string, req Publishing, result ResultEnum, payload interface{}) error {
+	var r Request
+	if err := json.Unmarshal(req.Body, &r); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"uuid":    req.CorrelationID,
+		"action":  r.Action,
+		"result":  result,
+		"payload": payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	t.DeliverRaw(user+"_i", req.CorrelationID, body)
+	return nil
+}
+
+// Closes current session as if broker dropped the connection, Client will reconnect.
+func (t *MemoryTransport) Disconnect(reason error) {
+	t.mu.Lock()
+	s := t.session
+	t.mu.Unlock()
+
+	if s != nil {
+		s.close(reason)
+	}
+}
+
+// Must be called with mutex locked.
+func (t *MemoryTransport) queue(name string) chan Delivery {
+	q, found := t.queues[name]
+	if !found {
+		q = make(chan Delivery, 1000)
+		t.queues[name] = q
+	}
+	return q
+}
+
+type memorySession struct {
+	transport  *MemoryTransport
+	closed     chan error
+	done       chan struct{}
+	once       sync.Once
+	cancelled  chan struct{}
+	cancelOnce sync.Once
+}
+
+func (s *memorySession) Publish(ctx context.Context, msg Publishing) error {
+	select {
+	case <-s.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	select {
+	case s.transport.requests <- msg:
+		return nil
+	default:
+		return errors.New("cwapi: memory transport requests buffer is full")
+	}
+}
+
+func (s *memorySession) Consume(queue string, autoAck bool, prefetch int) (<-chan Delivery, error) {
+	select {
+	case <-s.done:
+		return nil, ErrClosed
+	default:
+	}
+
+	s.transport.mu.Lock()
+	q := s.transport.queue(queue)
+	s.transport.mu.Unlock()
+
+	out := make(chan Delivery)
+	go func() {
+		defer close(out)
+
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-s.cancelled:
+				return
+			case d := <-q:
+				select {
+				case out <- d:
+				case <-s.done:
+					// not consumed, put it back
+					q <- d
+					return
+				case <-s.cancelled:
+					q <- d
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (s *memorySession) Cancel() error {
+	s.cancelOnce.Do(func() {
+		close(s.cancelled)
+	})
+	return nil
+}
+
+func (s *memorySession) NotifyClose() <-chan error {
+	return s.closed
+}
+
+func (s *memorySession) Close() error {
+	s.close(errors.New("session closed"))
+	return nil
+}
+
+func (s *memorySession) close(reason error) {
+	s.once.Do(func() {
+		close(s.done)
+		s.closed <- reason
+	})
+}
+
+// Puts nacked and rejected messages back to the queue if requeue is set.
+type memoryAcknowledger struct {
+	transport     *MemoryTransport
+	queue         string
+	correlationID string
+	body          []byte
+}
+
+func (a memoryAcknowledger) Ack(tag uint64, multiple bool) error {
+	return nil
+}
+
+func (a memoryAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	return a.Reject(tag, requeue)
+}
+
+func (a memoryAcknowledger) Reject(tag uint64, requeue bool) error {
+	if requeue {
+		a.transport.DeliverRaw(a.queue, a.correlationID, a.body)
+	}
+	return nil
+}
@@ -1,17 +1,14 @@
 package cwapi
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
-	"time"
 )
 
 // Access request from your application to the user.
 func (c *Client) CreateAuthCode(userID int) error {
-	payload, err := json.Marshal(&reqPayload{
-		reqCreateAuthCode: &reqCreateAuthCode{
-			userID,
-		},
+	payload, err := json.Marshal(&reqCreateAuthCode{
+		userID,
 	})
 	if err != nil {
 		return err
@@ -37,10 +34,16 @@ func (c *Client) CreateAuthCode(userID int) error {
 
 // Sync-version of CreateAuthCode method.
 func (c *Client) CreateAuthCodeSync(userID int) (*Response, error) {
-	payload, err := json.Marshal(&reqPayload{
-		reqCreateAuthCode: &reqCreateAuthCode{
-			userID,
-		},
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	return c.CreateAuthCodeSyncContext(ctx, userID)
+}
+
+// Same as CreateAuthCodeSync, but waits for the response until ctx is done.
+func (c *Client) CreateAuthCodeSyncContext(ctx context.Context, userID int) (*Response, error) {
+	payload, err := json.Marshal(&reqCreateAuthCode{
+		userID,
 	})
 	if err != nil {
 		return nil, err
@@ -56,35 +59,14 @@ func (c *Client) CreateAuthCodeSync(userID int) (*Response, error) {
 		return nil, err
 	}
 
-	err = c.makeRequest(body)
-	if err != nil {
-		return nil, err
-	}
-
-	waiter := make(chan Response, 1)
-	c.waiters.Store(userID, waiter)
-
-	select {
-	// wait response from main loop in NewClient()
-	case response := <-waiter:
-		if response.GetResultEnum() != Ok {
-			return &response, errors.New(string(response.GetResultEnum()))
-		}
-		return &response, nil
-		// or timeout
-	case <-time.After(10 * time.Second):
-		c.waiters.Delete(userID)
-		return nil, errors.New("timeout")
-	}
+	return c.makeSyncRequest(ctx, req.Action, body, userID)
 }
 
 // Exchange auth code for access token.
 func (c *Client) GrantToken(userID int, authCode string) error {
-	payload, err := json.Marshal(&reqPayload{
-		reqGrantToken: &reqGrantToken{
-			userID,
-			authCode,
-		},
+	payload, err := json.Marshal(&reqGrantToken{
+		userID,
+		authCode,
 	})
 	if err != nil {
 		return err
@@ -110,11 +92,17 @@ func (c *Client) GrantToken(userID int, authCode string) error {
 
 // Sync-version of GrantToken method.
 func (c *Client) GrantTokenSync(userID int, authCode string) (*Response, error) {
-	payload, err := json.Marshal(&reqPayload{
-		reqGrantToken: &reqGrantToken{
-			userID,
-			authCode,
-		},
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	return c.GrantTokenSyncContext(ctx, userID, authCode)
+}
+
+// Same as GrantTokenSync, but waits for the response until ctx is done.
+func (c *Client) GrantTokenSyncContext(ctx context.Context, userID int, authCode string) (*Response, error) {
+	payload, err := json.Marshal(&reqGrantToken{
+		userID,
+		authCode,
 	})
 	if err != nil {
 		return nil, err
@@ -130,32 +118,13 @@ func (c *Client) GrantTokenSync(userID int, authCode string) (*Response, error)
 		return nil, err
 	}
 
-	err = c.makeRequest(body)
-	if err != nil {
-		return nil, err
-	}
-
-	waiter := make(chan Response, 1)
-	c.waiters.Store(userID, waiter)
-
-	select {
-	case response := <-waiter:
-		if response.GetResultEnum() != Ok {
-			return &response, errors.New(string(response.GetResultEnum()))
-		}
-		return &response, nil
-	case <-time.After(10 * time.Second):
-		c.waiters.Delete(userID)
-		return nil, errors.New("timeout")
-	}
+	return c.makeSyncRequest(ctx, req.Action, body, userID)
 }
 
 // Sends request to broaden tokens operations set to user.
 func (c *Client) AuthAdditionalOperation(token string, operation string) error {
-	payload, err := json.Marshal(&reqPayload{
-		reqAuthAdditionalOperation: &reqAuthAdditionalOperation{
-			operation,
-		},
+	payload, err := json.Marshal(&reqAuthAdditionalOperation{
+		operation,
 	})
 	if err != nil {
 		return err
@@ -182,10 +151,16 @@ func (c *Client) AuthAdditionalOperation(token string, operation string) error {
 
 // Sync-version of AuthAdditionalOperation method.
 func (c *Client) AuthAdditionalOperationSync(token string, operation string, userID int) (*Response, error) {
-	payload, err := json.Marshal(&reqPayload{
-		reqAuthAdditionalOperation: &reqAuthAdditionalOperation{
-			operation,
-		},
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	return c.AuthAdditionalOperationSyncContext(ctx, token, operation, userID)
+}
+
+// Same as AuthAdditionalOperationSync, but waits for the response until ctx is done.
+func (c *Client) AuthAdditionalOperationSyncContext(ctx context.Context, token string, operation string, userID int) (*Response, error) {
+	payload, err := json.Marshal(&reqAuthAdditionalOperation{
+		operation,
 	})
 	if err != nil {
 		return nil, err
@@ -202,33 +177,14 @@ func (c *Client) AuthAdditionalOperationSync(token string, operation string, use
 		return nil, err
 	}
 
-	err = c.makeRequest(body)
-	if err != nil {
-		return nil, err
-	}
-
-	waiter := make(chan Response, 1)
-	c.waiters.Store(userID, waiter)
-
-	select {
-	case response := <-waiter:
-		if response.GetResultEnum() != Ok {
-			return &response, errors.New(string(response.GetResultEnum()))
-		}
-		return &response, nil
-	case <-time.After(10 * time.Second):
-		c.waiters.Delete(userID)
-		return nil, errors.New("timeout")
-	}
+	return c.makeSyncRequest(ctx, req.Action, body, userID)
 }
 
 // Completes the authAdditionalOperation action.
 func (c *Client) GrantAdditionalOperation(token string, requestedID string, authCode string) error {
-	payload, err := json.Marshal(&reqPayload{
-		reqGrantAdditionalOperation: &reqGrantAdditionalOperation{
-			requestedID,
-			authCode,
-		},
+	payload, err := json.Marshal(&reqGrantAdditionalOperation{
+		requestedID,
+		authCode,
 	})
 	if err != nil {
 		return err
@@ -255,11 +211,17 @@ func (c *Client) GrantAdditionalOperation(token string, requestedID string, auth
 
 // Sync-version of GrantAdditionalOperation method.
 func (c *Client) GrantAdditionalOperationSync(token string, requestedID string, authCode string, userID int) (*Response, error) {
-	payload, err := json.Marshal(&reqPayload{
-		reqGrantAdditionalOperation: &reqGrantAdditionalOperation{
-			requestedID,
-			authCode,
-		},
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	return c.GrantAdditionalOperationSyncContext(ctx, token, requestedID, authCode, userID)
+}
+
+// Same as GrantAdditionalOperationSync, but waits for the response until ctx is done.
+func (c *Client) GrantAdditionalOperationSyncContext(ctx context.Context, token string, requestedID string, authCode string, userID int) (*Response, error) {
+	payload, err := json.Marshal(&reqGrantAdditionalOperation{
+		requestedID,
+		authCode,
 	})
 	if err != nil {
 		return nil, err
@@ -276,34 +238,15 @@ func (c *Client) GrantAdditionalOperationSync(token string, requestedID string,
 		return nil, err
 	}
 
-	err = c.makeRequest(body)
-	if err != nil {
-		return nil, err
-	}
-
-	waiter := make(chan Response, 1)
-	c.waiters.Store(userID, waiter)
-
-	select {
-	case response := <-waiter:
-		if response.GetResultEnum() != Ok {
-			return &response, errors.New(string(response.GetResultEnum()))
-		}
-		return &response, nil
-	case <-time.After(10 * time.Second):
-		c.waiters.Delete(userID)
-		return nil, errors.New("timeout")
-	}
+	return c.makeSyncRequest(ctx, req.Action, body, userID)
 }
 
 // Sends authorization request to user with confirmation code in it.
 func (c *Client) AuthorizePayment(token string, transactionID string, pouchesAmount int) error {
-	payload, err := json.Marshal(&reqPayload{
-		reqAuthorizePayment: &reqAuthorizePayment{
-			transactionID,
-			map[string]int{
-				"pouches": pouchesAmount,
-			},
+	payload, err := json.Marshal(&reqAuthorizePayment{
+		transactionID,
+		map[string]int{
+			"pouches": pouchesAmount,
 		},
 	})
 	if err != nil {
@@ -331,12 +274,18 @@ func (c *Client) AuthorizePayment(token string, transactionID string, pouchesAmo
 
 // Sync-version of AuthorizePayment method.
 func (c *Client) AuthorizePaymentSync(token string, transactionID string, pouchesAmount int, userID int) (*Response, error) {
-	payload, err := json.Marshal(&reqPayload{
-		reqAuthorizePayment: &reqAuthorizePayment{
-			transactionID,
-			map[string]int{
-				"pouches": pouchesAmount,
-			},
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	return c.AuthorizePaymentSyncContext(ctx, token, transactionID, pouchesAmount, userID)
+}
+
+// Same as AuthorizePaymentSync, but waits for the response until ctx is done.
+func (c *Client) AuthorizePaymentSyncContext(ctx context.Context, token string, transactionID string, pouchesAmount int, userID int) (*Response, error) {
+	payload, err := json.Marshal(&reqAuthorizePayment{
+		transactionID,
+		map[string]int{
+			"pouches": pouchesAmount,
 		},
 	})
 	if err != nil {
@@ -354,36 +303,17 @@ func (c *Client) AuthorizePaymentSync(token string, transactionID string, pouche
 		return nil, err
 	}
 
-	err = c.makeRequest(body)
-	if err != nil {
-		return nil, err
-	}
-
-	waiter := make(chan Response, 1)
-	c.waiters.Store(userID, waiter)
-
-	select {
-	case response := <-waiter:
-		if response.GetResultEnum() != Ok {
-			return &response, errors.New(string(response.GetResultEnum()))
-		}
-		return &response, nil
-	case <-time.After(10 * time.Second):
-		c.waiters.Delete(userID)
-		return nil, errors.New("timeout")
-	}
+	return c.makeSyncRequest(ctx, req.Action, body, userID)
 }
 
 // Previously, transfers held an amount of gold from users account to application’s balance.
 func (c *Client) Pay(token string, transactionID string, pouchesAmount int, confirmCode string) error {
-	payload, err := json.Marshal(&reqPayload{
-		reqPay: &reqPay{
-			transactionID,
-			map[string]int{
-				"pouches": pouchesAmount,
-			},
-			confirmCode,
+	payload, err := json.Marshal(&reqPay{
+		transactionID,
+		map[string]int{
+			"pouches": pouchesAmount,
 		},
+		confirmCode,
 	})
 	if err != nil {
 		return err
@@ -410,14 +340,20 @@ func (c *Client) Pay(token string, transactionID string, pouchesAmount int, conf
 
 // Sync-version of Pay method.
 func (c *Client) PaySync(token string, transactionID string, pouchesAmount int, confirmCode string, userID int) (*Response, error) {
-	payload, err := json.Marshal(&reqPayload{
-		reqPay: &reqPay{
-			transactionID,
-			map[string]int{
-				"pouches": pouchesAmount,
-			},
-			confirmCode,
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	return c.PaySyncContext(ctx, token, transactionID, pouchesAmount, confirmCode, userID)
+}
+
+// Same as PaySync, but waits for the response until ctx is done.
+func (c *Client) PaySyncContext(ctx context.Context, token string, transactionID string, pouchesAmount int, confirmCode string, userID int) (*Response, error) {
+	payload, err := json.Marshal(&reqPay{
+		transactionID,
+		map[string]int{
+			"pouches": pouchesAmount,
 		},
+		confirmCode,
 	})
 	if err != nil {
 		return nil, err
@@ -434,36 +370,17 @@ func (c *Client) PaySync(token string, transactionID string, pouchesAmount int,
 		return nil, err
 	}
 
-	err = c.makeRequest(body)
-	if err != nil {
-		return nil, err
-	}
-
-	waiter := make(chan Response, 1)
-	c.waiters.Store(userID, waiter)
-
-	select {
-	case response := <-waiter:
-		if response.GetResultEnum() != Ok {
-			return &response, errors.New(string(response.GetResultEnum()))
-		}
-		return &response, nil
-	case <-time.After(10 * time.Second):
-		c.waiters.Delete(userID)
-		return nil, errors.New("timeout")
-	}
+	return c.makeSyncRequest(ctx, req.Action, body, userID)
 }
 
 // Transfers of a given amount of gold (or pouches) from the application’s balance to users account.
 func (c *Client) Payout(token string, transactionID string, pouchesAmount int, message string) error {
-	payload, err := json.Marshal(&reqPayload{
-		reqPayout: &reqPayout{
-			transactionID,
-			map[string]int{
-				"pouches": pouchesAmount,
-			},
-			message,
+	payload, err := json.Marshal(&reqPayout{
+		transactionID,
+		map[string]int{
+			"pouches": pouchesAmount,
 		},
+		message,
 	})
 	if err != nil {
 		return err
@@ -490,14 +407,20 @@ func (c *Client) Payout(token string, transactionID string, pouchesAmount int, m
 
 // Sync-version of Payout method.
 func (c *Client) PayoutSync(token string, transactionID string, pouchesAmount int, message string, userID int) (*Response, error) {
-	payload, err := json.Marshal(&reqPayload{
-		reqPayout: &reqPayout{
-			transactionID,
-			map[string]int{
-				"pouches": pouchesAmount,
-			},
-			message,
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	return c.PayoutSyncContext(ctx, token, transactionID, pouchesAmount, message, userID)
+}
+
+// Same as PayoutSync, but waits for the response until ctx is done.
+func (c *Client) PayoutSyncContext(ctx context.Context, token string, transactionID string, pouchesAmount int, message string, userID int) (*Response, error) {
+	payload, err := json.Marshal(&reqPayout{
+		transactionID,
+		map[string]int{
+			"pouches": pouchesAmount,
 		},
+		message,
 	})
 	if err != nil {
 		return nil, err
@@ -514,24 +437,7 @@ func (c *Client) PayoutSync(token string, transactionID string, pouchesAmount in
 		return nil, err
 	}
 
-	err = c.makeRequest(body)
-	if err != nil {
-		return nil, err
-	}
-
-	waiter := make(chan Response, 1)
-	c.waiters.Store(userID, waiter)
-
-	select {
-	case response := <-waiter:
-		if response.GetResultEnum() != Ok {
-			return &response, errors.New(string(response.GetResultEnum()))
-		}
-		return &response, nil
-	case <-time.After(10 * time.Second):
-		c.waiters.Delete(userID)
-		return nil, errors.New("timeout")
-	}
+	return c.makeSyncRequest(ctx, req.Action, body, userID)
 }
 
 // Request current info about your application. E.g. balance, limits, status.
@@ -553,6 +459,28 @@ func (c *Client) GetInfo() error {
 	return nil
 }
 
+// Sync-version of GetInfo method.
+func (c *Client) GetInfoSync() (*Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	return c.GetInfoSyncContext(ctx)
+}
+
+// Same as GetInfoSync, but waits for the response until ctx is done.
+func (c *Client) GetInfoSyncContext(ctx context.Context) (*Response, error) {
+	req := &Request{
+		Action: "getInfo",
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.makeSyncRequest(ctx, req.Action, body, 0)
+}
+
 // Request the list of recipes known to user.
 func (c *Client) ViewCraftbook(token string) error {
 	req := &Request{
@@ -575,6 +503,14 @@ func (c *Client) ViewCraftbook(token string) error {
 
 // Sync-version of ViewCraftbook method.
 func (c *Client) ViewCraftbookSync(token string, userID int) (*Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	return c.ViewCraftbookSyncContext(ctx, token, userID)
+}
+
+// Same as ViewCraftbookSync, but waits for the response until ctx is done.
+func (c *Client) ViewCraftbookSyncContext(ctx context.Context, token string, userID int) (*Response, error) {
 	req := &Request{
 		Action: "viewCraftbook",
 		Token:  token,
@@ -585,24 +521,7 @@ func (c *Client) ViewCraftbookSync(token string, userID int) (*Response, error)
 		return nil, err
 	}
 
-	err = c.makeRequest(body)
-	if err != nil {
-		return nil, err
-	}
-
-	waiter := make(chan Response, 1)
-	c.waiters.Store(userID, waiter)
-
-	select {
-	case response := <-waiter:
-		if response.GetResultEnum() != Ok {
-			return &response, errors.New(string(response.GetResultEnum()))
-		}
-		return &response, nil
-	case <-time.After(10 * time.Second):
-		c.waiters.Delete(userID)
-		return nil, errors.New("timeout")
-	}
+	return c.makeSyncRequest(ctx, req.Action, body, userID)
 }
 
 // Request brief user profile information.
@@ -627,6 +546,14 @@ func (c *Client) RequestProfile(token string) error {
 
 // Sync-version of RequstProfile method.
 func (c *Client) RequestProfileSync(token string, userID int) (*Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	return c.RequestProfileSyncContext(ctx, token, userID)
+}
+
+// Same as RequestProfileSync, but waits for the response until ctx is done.
+func (c *Client) RequestProfileSyncContext(ctx context.Context, token string, userID int) (*Response, error) {
 	req := &Request{
 		Action: "requestProfile",
 		Token:  token,
@@ -637,24 +564,7 @@ func (c *Client) RequestProfileSync(token string, userID int) (*Response, error)
 		return nil, err
 	}
 
-	err = c.makeRequest(body)
-	if err != nil {
-		return nil, err
-	}
-
-	waiter := make(chan Response, 1)
-	c.waiters.Store(userID, waiter)
-
-	select {
-	case response := <-waiter:
-		if response.GetResultEnum() != Ok {
-			return &response, errors.New(string(response.GetResultEnum()))
-		}
-		return &response, nil
-	case <-time.After(10 * time.Second):
-		c.waiters.Delete(userID)
-		return nil, errors.New("timeout")
-	}
+	return c.makeSyncRequest(ctx, req.Action, body, userID)
 }
 
 // Request basic user stats.
@@ -680,6 +590,14 @@ func (c *Client) RequestBasicInfo(token string) error {
 
 // Sync-version of RequestBasicInfo method.
 func (c *Client) RequestBasicInfoSync(token string, userID int) (*Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	return c.RequestBasicInfoSyncContext(ctx, token, userID)
+}
+
+// Same as RequestBasicInfoSync, but waits for the response until ctx is done.
+func (c *Client) RequestBasicInfoSyncContext(ctx context.Context, token string, userID int) (*Response, error) {
 	req := &Request{
 		Action: "requestBasicInfo",
 		Token:  token,
@@ -690,24 +608,7 @@ func (c *Client) RequestBasicInfoSync(token string, userID int) (*Response, erro
 		return nil, err
 	}
 
-	err = c.makeRequest(body)
-	if err != nil {
-		return nil, err
-	}
-
-	waiter := make(chan Response, 1)
-	c.waiters.Store(userID, waiter)
-
-	select {
-	case response := <-waiter:
-		if response.GetResultEnum() != Ok {
-			return &response, errors.New(string(response.GetResultEnum()))
-		}
-		return &response, nil
-	case <-time.After(10 * time.Second):
-		c.waiters.Delete(userID)
-		return nil, errors.New("timeout")
-	}
+	return c.makeSyncRequest(ctx, req.Action, body, userID)
 }
 
 // Request user’s current outfit.
@@ -733,6 +634,14 @@ func (c *Client) RequestGearInfo(token string) error {
 
 // Sync-version of RequestGearInfo method.
 func (c *Client) RequestGearInfoSync(token string, userID int) (*Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	return c.RequestGearInfoSyncContext(ctx, token, userID)
+}
+
+// Same as RequestGearInfoSync, but waits for the response until ctx is done.
+func (c *Client) RequestGearInfoSyncContext(ctx context.Context, token string, userID int) (*Response, error) {
 	req := &Request{
 		Action: "requestGearInfo",
 		Token:  token,
@@ -743,24 +652,7 @@ func (c *Client) RequestGearInfoSync(token string, userID int) (*Response, error
 		return nil, err
 	}
 
-	err = c.makeRequest(body)
-	if err != nil {
-		return nil, err
-	}
-
-	waiter := make(chan Response, 1)
-	c.waiters.Store(userID, waiter)
-
-	select {
-	case response := <-waiter:
-		if response.GetResultEnum() != Ok {
-			return &response, errors.New(string(response.GetResultEnum()))
-		}
-		return &response, nil
-	case <-time.After(10 * time.Second):
-		c.waiters.Delete(userID)
-		return nil, errors.New("timeout")
-	}
+	return c.makeSyncRequest(ctx, req.Action, body, userID)
 }
 
 // Request users stock information.
@@ -785,6 +677,14 @@ func (c *Client) RequestStock(token string) error {
 
 // Sync-version of RequestStock method.
 func (c *Client) RequestStockSync(token string, userID int) (*Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	return c.RequestStockSyncContext(ctx, token, userID)
+}
+
+// Same as RequestStockSync, but waits for the response until ctx is done.
+func (c *Client) RequestStockSyncContext(ctx context.Context, token string, userID int) (*Response, error) {
 	req := &Request{
 		Action: "requestStock",
 		Token:  token,
@@ -795,24 +695,7 @@ func (c *Client) RequestStockSync(token string, userID int) (*Response, error) {
 		return nil, err
 	}
 
-	err = c.makeRequest(body)
-	if err != nil {
-		return nil, err
-	}
-
-	waiter := make(chan Response, 1)
-	c.waiters.Store(userID, waiter)
-
-	select {
-	case response := <-waiter:
-		if response.GetResultEnum() != Ok {
-			return &response, errors.New(string(response.GetResultEnum()))
-		}
-		return &response, nil
-	case <-time.After(10 * time.Second):
-		c.waiters.Delete(userID)
-		return nil, errors.New("timeout")
-	}
+	return c.makeSyncRequest(ctx, req.Action, body, userID)
 }
 
 // Request users guild information.
@@ -838,6 +721,14 @@ func (c *Client) GuildInfo(token string) error {
 
 // Sync-version of GuildInfo method.
 func (c *Client) GuildInfoSync(token string, userID int) (*Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	return c.GuildInfoSyncContext(ctx, token, userID)
+}
+
+// Same as GuildInfoSync, but waits for the response until ctx is done.
+func (c *Client) GuildInfoSyncContext(ctx context.Context, token string, userID int) (*Response, error) {
 	req := &Request{
 		Action: "guildInfo",
 		Token:  token,
@@ -848,35 +739,16 @@ func (c *Client) GuildInfoSync(token string, userID int) (*Response, error) {
 		return nil, err
 	}
 
-	err = c.makeRequest(body)
-	if err != nil {
-		return nil, err
-	}
-
-	waiter := make(chan Response, 1)
-	c.waiters.Store(userID, waiter)
-
-	select {
-	case response := <-waiter:
-		if response.GetResultEnum() != Ok {
-			return &response, errors.New(string(response.GetResultEnum()))
-		}
-		return &response, nil
-	case <-time.After(10 * time.Second):
-		c.waiters.Delete(userID)
-		return nil, errors.New("timeout")
-	}
+	return c.makeSyncRequest(ctx, req.Action, body, userID)
 }
 
 // Buys something on exchange.
 func (c *Client) WantToBuy(token string, itemCode string, quantity int, price int, exactPrice bool) error {
-	payload, err := json.Marshal(&reqPayload{
-		reqWantToBuy: &reqWantToBuy{
-			ItemCode:   itemCode,
-			Quantity:   quantity,
-			Price:      price,
-			ExactPrice: exactPrice,
-		},
+	payload, err := json.Marshal(&reqWantToBuy{
+		ItemCode:   itemCode,
+		Quantity:   quantity,
+		Price:      price,
+		ExactPrice: exactPrice,
 	})
 	if err != nil {
 		return err
@@ -903,13 +775,19 @@ func (c *Client) WantToBuy(token string, itemCode string, quantity int, price in
 
 // Buys something on exchange.
 func (c *Client) WantToBuySync(token string, itemCode string, quantity int, price int, exactPrice bool, userID int) (*Response, error) {
-	payload, err := json.Marshal(&reqPayload{
-		reqWantToBuy: &reqWantToBuy{
-			ItemCode:   itemCode,
-			Quantity:   quantity,
-			Price:      price,
-			ExactPrice: exactPrice,
-		},
+	ctx, cancel := context.WithTimeout(context.Background(), c.syncTimeout)
+	defer cancel()
+
+	return c.WantToBuySyncContext(ctx, token, itemCode, quantity, price, exactPrice, userID)
+}
+
+// Same as WantToBuySync, but waits for the response until ctx is done.
+func (c *Client) WantToBuySyncContext(ctx context.Context, token string, itemCode string, quantity int, price int, exactPrice bool, userID int) (*Response, error) {
+	payload, err := json.Marshal(&reqWantToBuy{
+		ItemCode:   itemCode,
+		Quantity:   quantity,
+		Price:      price,
+		ExactPrice: exactPrice,
 	})
 	if err != nil {
 		return nil, err
@@ -926,22 +804,5 @@ func (c *Client) WantToBuySync(token string, itemCode string, quantity int, pric
 		return nil, err
 	}
 
-	err = c.makeRequest(body)
-	if err != nil {
-		return nil, err
-	}
-
-	waiter := make(chan Response, 1)
-	c.waiters.Store(userID, waiter)
-
-	select {
-	case response := <-waiter:
-		if response.GetResultEnum() != Ok {
-			return &response, errors.New(string(response.GetResultEnum()))
-		}
-		return &response, nil
-	case <-time.After(10 * time.Second):
-		c.waiters.Delete(userID)
-		return nil, errors.New("timeout")
-	}
+	return c.makeSyncRequest(ctx, req.Action, body, userID)
 }
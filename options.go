@@ -0,0 +1,260 @@
+package cwapi
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/streadway/amqp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Stream is identified by its queue suffix, e.g. queue "login_deals" belongs to StreamDeals.
+type Stream string
+
+const (
+	StreamUpdates       Stream = "i"
+	StreamDeals         Stream = "deals"
+	StreamDuels         Stream = "duels"
+	StreamOffers        Stream = "offers"
+	StreamSexDigest     Stream = "sex_digest"
+	StreamYellowPages   Stream = "yellow_pages"
+	StreamAuctionDigest Stream = "au_digest"
+)
+
+// Default channel buffer sizes of the streams.
+var defaultBufferSizes = map[Stream]int{
+	StreamUpdates:       100,
+	StreamDeals:         100,
+	StreamDuels:         100,
+	StreamOffers:        100,
+	StreamSexDigest:     1,
+	StreamYellowPages:   1,
+	StreamAuctionDigest: 1,
+}
+
+type options struct {
+	url             string
+	amqpConfig      amqp.Config
+	transport       Transport
+	bufferSizes     map[Stream]int
+	syncTimeout     time.Duration
+	logger          Logger
+	reconnectPolicy ReconnectPolicy
+	retryPolicy     RetryPolicy
+	router          *Router
+	overflow        map[Stream]OverflowPolicy
+	spillDir        string
+	deadLetterPath  string
+	tokens          TokenStore
+	ledger          *Ledger
+}
+
+// Configures Client created by New.
+type Option func(*options)
+
+// Selects Chat Wars server: cw2, eu, cw3 or ru. Defaults to Chat Wars 2 (or EU).
+func WithServer(server string) Option {
+	return func(o *options) {
+		switch strings.ToLower(server) {
+		case "cw2", "eu":
+			o.url = CW2
+		case "cw3", "ru":
+			o.url = CW3
+		}
+	}
+}
+
+// Sets custom broker URL. It may contain two %s verbs, they are replaced with user and password,
+// e.g. "amqps://%s:%s@localhost:5673/".
+func WithURL(url string) Option {
+	return func(o *options) {
+		o.url = url
+	}
+}
+
+// Sets TLS config used for amqps connections.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *options) {
+		o.amqpConfig.TLSClientConfig = config
+	}
+}
+
+// Sets connection timeout, defaults to 30 seconds.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.amqpConfig.Dial = amqp.DefaultDial(timeout)
+	}
+}
+
+// Sets heartbeat interval, defaults to 10 seconds.
+func WithHeartbeat(interval time.Duration) Option {
+	return func(o *options) {
+		o.amqpConfig.Heartbeat = interval
+	}
+}
+
+// Sets buffer size of the stream channel, e.g. Client.Updates for StreamUpdates.
+func WithBufferSize(stream Stream, size int) Option {
+	return func(o *options) {
+		o.bufferSizes[stream] = size
+	}
+}
+
+// Sets what happens when application reads the stream channel slower than messages arrive,
+// defaults to OverflowBlock. See Client.Stats for dropped and spilled counters.
+func WithOverflowPolicy(stream Stream, policy OverflowPolicy) Option {
+	return func(o *options) {
+		o.overflow[stream] = policy
+	}
+}
+
+// Sets directory for spill files of streams with OverflowSpill policy, defaults to os.TempDir().
+func WithSpillDir(dir string) Option {
+	return func(o *options) {
+		o.spillDir = dir
+	}
+}
+
+// Sets file where malformed deliveries are appended as JSON lines with raw body, queue name and decode error.
+func WithDeadLetterFile(path string) Option {
+	return func(o *options) {
+		o.deadLetterPath = path
+	}
+}
+
+// Sets how long Sync methods wait for the response, defaults to 10 seconds.
+// SyncContext methods use context deadline instead.
+func WithSyncTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.syncTimeout = timeout
+	}
+}
+
+// Sets logger, e.g. *slog.Logger. Defaults to the one writing to stderr like standard logger does,
+// pass nil or DiscardLogger to silence it entirely.
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		if logger == nil {
+			logger = DiscardLogger
+		}
+		o.logger = logger
+	}
+}
+
+// Sets reconnection policy, defaults to DefaultReconnectPolicy.
+func WithReconnectPolicy(policy ReconnectPolicy) Option {
+	return func(o *options) {
+		o.reconnectPolicy = policy
+	}
+}
+
+// Sets retry policy, by default requests are not retried. See RetryPolicy and DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retryPolicy = policy
+	}
+}
+
+// Sets router which handles every response instead of sending it to Client.Updates.
+func WithRouter(router *Router) Option {
+	return func(o *options) {
+		o.router = router
+	}
+}
+
+// Sets store where granted tokens are kept, see TokenStore and Client.Token.
+func WithTokenStore(store TokenStore) Option {
+	return func(o *options) {
+		o.tokens = store
+	}
+}
+
+// Sets ledger where every pay and payout request and response is recorded, see Ledger.
+func WithLedger(ledger *Ledger) Option {
+	return func(o *options) {
+		o.ledger = ledger
+	}
+}
+
+// Sets custom transport, e.g. MemoryTransport in tests. URL, TLS, dial timeout and heartbeat options are ignored then.
+func WithTransport(transport Transport) Option {
+	return func(o *options) {
+		o.transport = transport
+	}
+}
+
+// Create new client configured by options:
+//
+//	client, err := cwapi.New("login", "password",
+//		cwapi.WithServer("cw3"),
+//		cwapi.WithSyncTimeout(5*time.Second),
+//		cwapi.WithBufferSize(cwapi.StreamDeals, 1000),
+//	)
+func New(user string, password string, opts ...Option) (*Client, error) {
+	o := options{
+		url:             CW2,
+		bufferSizes:     make(map[Stream]int),
+		overflow:        make(map[Stream]OverflowPolicy),
+		spillDir:        os.TempDir(),
+		syncTimeout:     defaultSyncTimeout,
+		logger:          defaultLogger,
+		reconnectPolicy: DefaultReconnectPolicy,
+	}
+	for stream, size := range defaultBufferSizes {
+		o.bufferSizes[stream] = size
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	rabbitUrl := o.url
+	if strings.Count(rabbitUrl, "%s") == 2 {
+		rabbitUrl = fmt.Sprintf(rabbitUrl, user, password)
+	}
+
+	transport := o.transport
+	if transport == nil {
+		transport = &AMQPTransport{
+			URL:    rabbitUrl,
+			Config: o.amqpConfig,
+		}
+	}
+
+	var deadLetter *deadLetterFile
+	if o.deadLetterPath != "" {
+		f, err := openDeadLetterFile(o.deadLetterPath)
+		if err != nil {
+			return nil, err
+		}
+		deadLetter = f
+	}
+
+	client := &Client{
+		User:             user,
+		Password:         password,
+		RabbitUrl:        rabbitUrl,
+		transport:        transport,
+		bufferSizes:      o.bufferSizes,
+		syncTimeout:      o.syncTimeout,
+		logger:           o.logger,
+		reconnectPolicy:  o.reconnectPolicy,
+		retryPolicy:      o.retryPolicy,
+		router:           o.router,
+		overflowPolicies: o.overflow,
+		spillDir:         o.spillDir,
+		deadLetter:       deadLetter,
+		tokens:           o.tokens,
+		ledger:           o.ledger,
+	}
+
+	if err := client.start(); err != nil {
+		// release dead letter file and streams opened so far
+		client.Close(context.Background())
+		return client, err
+	}
+
+	return client, nil
+}
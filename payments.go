@@ -0,0 +1,245 @@
+package cwapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// Returned by Payments when there is no payment with such transaction ID.
+	ErrPaymentNotFound = errors.New("cwapi: payment not found")
+	// Returned by Payments.Confirm for payment which is already paid, so it's never paid twice.
+	ErrAlreadyPaid = errors.New("cwapi: payment is already paid")
+)
+
+type PaymentState string
+
+const (
+	// authorizePayment was sent
+	PaymentAuthorizing PaymentState = "authorizing"
+	// Payment was authorized, waiting for confirmation code from user
+	PaymentAwaitingCode PaymentState = "awaiting_code"
+	// pay was sent
+	PaymentPaying PaymentState = "paying"
+	// Money was transferred
+	PaymentPaid PaymentState = "paid"
+	// Server rejected payment or authorization didn't match expectations
+	PaymentFailed PaymentState = "failed"
+)
+
+// Single payment tracked by Payments.
+type Payment struct {
+	TransactionID string
+	UserID        int
+	// Amount in pouches
+	Amount int
+	State  PaymentState
+	// Returned by authorizePayment
+	Fee   map[string]int
+	Debit map[string]int
+	// Reason of PaymentFailed state
+	Err       error
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	token string
+}
+
+// Returned by Payments.Authorize when authorizePayment response doesn't match the payment.
+type PaymentMismatchError struct {
+	TransactionID string
+	Reason        string
+}
+
+func (e *PaymentMismatchError) Error() string {
+	return fmt.Sprintf("cwapi: payment %s authorization mismatch: %s", e.TransactionID, e.Reason)
+}
+
+// Drives authorizePayment → pay sequence, so every payment gets unique transaction ID,
+// is paid with the same ID and amount it was authorized with, and never paid twice:
+//
+//	payments := cwapi.NewPayments(client)
+//	payments.MaxFee = 10
+//
+//	p, err := payments.Authorize(ctx, token, userID, 5)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	// user sent confirmation code
+//	p, err = payments.Confirm(ctx, p.TransactionID, code)
+//
+// Payments is safe for concurrent use.
+type Payments struct {
+	client *Client
+
+	// Maximum fee in gold, zero means no limit
+	MaxFee int
+	// Additional check of authorizePayment response, returned error fails the payment
+	Validate func(p Payment, res *ResAuthorizePayment) error
+
+	mu       sync.Mutex
+	payments map[string]*Payment
+}
+
+// Creates payment manager on top of client.
+func NewPayments(client *Client) *Payments {
+	return &Payments{
+		client:   client,
+		payments: make(map[string]*Payment),
+	}
+}
+
+// Creates payment with new transaction ID and requests authorization from user, who receives confirmation code then.
+func (m *Payments) Authorize(ctx context.Context, token string, userID int, pouches int) (Payment, error) {
+	now := time.Now()
+	p := &Payment{
+		TransactionID: newCorrelationID(),
+		UserID:        userID,
+		Amount:        pouches,
+		State:         PaymentAuthorizing,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		token:         token,
+	}
+
+	m.mu.Lock()
+	m.payments[p.TransactionID] = p
+	m.mu.Unlock()
+
+	res, err := m.client.AuthorizePaymentSyncContext(ctx, token, p.TransactionID, pouches, userID)
+	if err != nil {
+		return m.fail(p, err)
+	}
+
+	auth := res.Payload.ResAuthorizePayment
+	if err := m.validate(p, auth); err != nil {
+		return m.fail(p, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p.Fee = auth.Fee
+	p.Debit = auth.Debit
+	p.State = PaymentAwaitingCode
+	p.UpdatedAt = time.Now()
+
+	return *p, nil
+}
+
+// Checks that authorization belongs to the payment, debits the payment amount and fee is acceptable.
+func (m *Payments) validate(p *Payment, auth *ResAuthorizePayment) error {
+	if auth.TransactionId != "" && auth.TransactionId != p.TransactionID {
+		return &PaymentMismatchError{p.TransactionID, fmt.Sprintf("response has transaction %s", auth.TransactionId)}
+	}
+	if auth.UserID != 0 && auth.UserID != p.UserID {
+		return &PaymentMismatchError{p.TransactionID, fmt.Sprintf("response has user %d", auth.UserID)}
+	}
+	// debit is the amount plus fee, currencies may differ, so they are compared in gold
+	if auth.Debit != nil && inGold(auth.Debit)-inGold(auth.Fee) != p.Amount*goldPerPouch {
+		return &PaymentMismatchError{p.TransactionID, fmt.Sprintf("debit of %d gold with fee %d doesn't match %d pouches",
+			inGold(auth.Debit), inGold(auth.Fee), p.Amount)}
+	}
+	if m.MaxFee > 0 && inGold(auth.Fee) > m.MaxFee {
+		return &PaymentMismatchError{p.TransactionID, fmt.Sprintf("fee %d exceeds %d", inGold(auth.Fee), m.MaxFee)}
+	}
+	if m.Validate != nil {
+		return m.Validate(*p, auth)
+	}
+	return nil
+}
+
+// Pays authorized payment with confirmation code user received. Wrong code keeps payment
+// waiting for the code, so user can try again. Confirm never sends pay twice for the same payment.
+func (m *Payments) Confirm(ctx context.Context, transactionID string, code string) (Payment, error) {
+	m.mu.Lock()
+	p, found := m.payments[transactionID]
+	if !found {
+		m.mu.Unlock()
+		return Payment{}, ErrPaymentNotFound
+	}
+
+	switch p.State {
+	case PaymentPaid:
+		m.mu.Unlock()
+		return *p, ErrAlreadyPaid
+	case PaymentAwaitingCode:
+	default:
+		m.mu.Unlock()
+		return *p, fmt.Errorf("cwapi: payment %s is %s, can't confirm it", transactionID, p.State)
+	}
+
+	p.State = PaymentPaying
+	p.UpdatedAt = time.Now()
+	m.mu.Unlock()
+
+	_, err := m.client.PaySyncContext(ctx, p.token, p.TransactionID, p.Amount, code, p.UserID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p.UpdatedAt = time.Now()
+	switch e, ok := AsAPIError(err); {
+	case err == nil:
+		p.State = PaymentPaid
+	case ok && e.Result != AuthorizationFailed && e.Result != TryAgain:
+		p.State = PaymentFailed
+		p.Err = err
+	default:
+		// Wrong code or no answer. Authorization is bound to the transaction ID and server
+		// transfers money of a transaction only once, so pay repeated with the same ID after
+		// timeout either completes the transfer which didn't happen or is rejected, never pays twice.
+		p.State = PaymentAwaitingCode
+	}
+
+	return *p, err
+}
+
+// Returns payment by transaction ID.
+func (m *Payments) Get(transactionID string) (Payment, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, found := m.payments[transactionID]
+	if !found {
+		return Payment{}, false
+	}
+	return *p, true
+}
+
+// Returns payments of the user.
+func (m *Payments) ByUser(userID int) []Payment {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var payments []Payment
+	for _, p := range m.payments {
+		if p.UserID == userID {
+			payments = append(payments, *p)
+		}
+	}
+	return payments
+}
+
+// Forgets payment, e.g. once it's stored elsewhere.
+func (m *Payments) Remove(transactionID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.payments, transactionID)
+}
+
+func (m *Payments) fail(p *Payment, err error) (Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p.State = PaymentFailed
+	p.Err = err
+	p.UpdatedAt = time.Now()
+
+	return *p, err
+}
@@ -0,0 +1,96 @@
+package cwapi
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// Answers authorizePayment of user 7 with fee of 5 gold and given debit.
+func replyAuthorize(transport *MemoryTransport, debit map[string]int) {
+	req := <-transport.Requests()
+
+	var r struct {
+		Payload reqAuthorizePayment `json:"payload"`
+	}
+	json.Unmarshal(req.Body, &r)
+
+	transport.Reply("login", req, Ok, ResAuthorizePayment{
+		Fee:           map[string]int{"gold": 5},
+		Debit:         debit,
+		UserID:        7,
+		TransactionId: r.Payload.TransactionID,
+	})
+}
+
+func TestPaymentsAuthorizeAndConfirm(t *testing.T) {
+	client, transport := newTestClient(t)
+	defer closeTestClient(client)
+
+	payments := NewPayments(client)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	go replyAuthorize(transport, map[string]int{"gold": 505})
+	p, err := payments.Authorize(ctx, "tok", 7, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.State != PaymentAwaitingCode {
+		t.Fatalf("unexpected state %s", p.State)
+	}
+
+	go func() {
+		req := <-transport.Requests()
+		transport.Reply("login", req, AuthorizationFailed, map[string]interface{}{"userId": 7})
+	}()
+	if p, _ = payments.Confirm(ctx, p.TransactionID, "wrong"); p.State != PaymentAwaitingCode {
+		t.Fatalf("wrong code should keep payment waiting, got %s", p.State)
+	}
+
+	go func() {
+		req := <-transport.Requests()
+		transport.Reply("login", req, Ok, ResPay{UserID: 7, TransactionId: p.TransactionID})
+	}()
+	if p, err = payments.Confirm(ctx, p.TransactionID, "right"); err != nil || p.State != PaymentPaid {
+		t.Fatalf("unexpected result %s: %v", p.State, err)
+	}
+
+	if _, err := payments.Confirm(ctx, p.TransactionID, "right"); err != ErrAlreadyPaid {
+		t.Fatalf("expected ErrAlreadyPaid, got %v", err)
+	}
+}
+
+func TestPaymentsRejectsDebitMismatch(t *testing.T) {
+	client, transport := newTestClient(t)
+	defer closeTestClient(client)
+
+	payments := NewPayments(client)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	go replyAuthorize(transport, map[string]int{"gold": 5005})
+	p, err := payments.Authorize(ctx, "tok", 7, 5)
+	if _, ok := err.(*PaymentMismatchError); !ok {
+		t.Fatalf("expected mismatch error, got %v", err)
+	}
+	if p.State != PaymentFailed {
+		t.Fatalf("unexpected state %s", p.State)
+	}
+}
+
+func TestPaymentsAcceptsDebitInMixedCurrencies(t *testing.T) {
+	client, transport := newTestClient(t)
+	defer closeTestClient(client)
+
+	payments := NewPayments(client)
+	payments.MaxFee = 5
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	go replyAuthorize(transport, map[string]int{"pouches": 5, "gold": 5})
+	if p, err := payments.Authorize(ctx, "tok", 7, 5); err != nil || p.State != PaymentAwaitingCode {
+		t.Fatalf("unexpected result %s: %v", p.State, err)
+	}
+}
@@ -0,0 +1,119 @@
+package cwapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Delivery which couldn't be decoded. It's never sent to the stream channel,
+// instead it goes to Client.DecodeErrors and dead-letter file if they are set up.
+type DecodeError struct {
+	Time   time.Time
+	Stream Stream
+	Queue  string
+	Body   []byte
+	Err    error
+}
+
+func (e DecodeError) Error() string {
+	return fmt.Sprintf("cwapi: cannot decode message from %s: %s", e.Queue, e.Err)
+}
+
+// Initializes DecodeErrors channel, malformed deliveries of every stream are sent there.
+// Errors are dropped if the channel buffer is full, so nobody is blocked by poison messages.
+func (c *Client) InitDecodeErrors() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closing {
+		return ErrClientClosed
+	}
+
+	c.DecodeErrors = make(chan DecodeError, 100)
+	return nil
+}
+
+// Diverts malformed delivery to DecodeErrors channel and dead-letter file. Delivery should be acked after that,
+// requeueing it would make it come back forever.
+func (c *Client) handlePoison(stream Stream, body []byte, err error) {
+	e := DecodeError{
+		Time:   time.Now(),
+		Stream: stream,
+		Queue:  fmt.Sprintf("%s_%s", c.User, stream),
+		Body:   body,
+		Err:    err,
+	}
+
+	c.logger.Error("cannot decode delivery",
+		"stream", stream,
+		"error", err,
+	)
+
+	if c.deadLetter != nil {
+		if err := c.deadLetter.write(&e); err != nil {
+			c.logger.Error("cannot write dead letter", "stream", stream, "error", err)
+		}
+	}
+
+	c.mu.RLock()
+	ch := c.DecodeErrors
+	c.mu.RUnlock()
+
+	if ch != nil {
+		select {
+		case ch <- e:
+		default:
+			c.logger.Warn("decode error dropped, DecodeErrors channel is full", "stream", stream)
+		}
+	}
+}
+
+// Appends malformed deliveries to file, one JSON object per line.
+type deadLetterFile struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func openDeadLetterFile(path string) (*deadLetterFile, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &deadLetterFile{file: file}, nil
+}
+
+func (f *deadLetterFile) write(e *DecodeError) error {
+	line, err := json.Marshal(struct {
+		Time   time.Time `json:"time"`
+		Stream Stream    `json:"stream"`
+		Queue  string    `json:"queue"`
+		Error  string    `json:"error"`
+		Body   string    `json:"body"`
+	}{
+		Time:   e.Time,
+		Stream: e.Stream,
+		Queue:  e.Queue,
+		Error:  e.Err.Error(),
+		Body:   string(e.Body),
+	})
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, err = f.file.Write(append(line, '\n'))
+	return err
+}
+
+func (f *deadLetterFile) close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
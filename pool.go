@@ -0,0 +1,369 @@
+package cwapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Returned by Pool methods for account name which wasn't added to the pool.
+var ErrUnknownAccount = errors.New("cwapi: unknown account")
+
+// Registered application credentials managed by Pool.
+type Account struct {
+	// Unique name calls are routed by, defaults to User
+	Name     string
+	User     string
+	Password string
+	// cw2, eu, cw3 or ru, see WithServer. Empty keeps server of options passed to NewPool,
+	// account is labeled by broker URL then
+	Server string
+	// Applied after options passed to NewPool
+	Options []Option
+}
+
+// Response received by one of pool accounts, see Pool.MergeUpdates.
+type PoolUpdate struct {
+	Account  string
+	Server   string
+	Response Response
+}
+
+// Connection states of pool accounts, see Pool.Health.
+type PoolHealth struct {
+	States    map[string]ConnectionState
+	Connected int
+	Total     int
+}
+
+// Reports whether every account is connected.
+func (h PoolHealth) Healthy() bool {
+	return h.Connected == h.Total
+}
+
+// Manages clients of several applications, possibly on different servers:
+//
+//	pool := cwapi.NewPool(cwapi.WithSyncTimeout(5 * time.Second))
+//	pool.Add(cwapi.Account{Name: "eu", User: "login", Password: "password", Server: "cw2"})
+//	pool.Add(cwapi.Account{Name: "ru", User: "login2", Password: "password2", Server: "cw3"})
+//
+//	client, _ := pool.Client("ru")
+//	client.RequestStockSync(token, userID)
+//
+// Every client keeps its own Updates channel, call MergeUpdates to receive updates of all accounts from one channel instead.
+type Pool struct {
+	mu      sync.RWMutex
+	opts    []Option
+	members map[string]*poolMember
+	// account names in order they were added
+	names []string
+
+	merged  chan PoolUpdate
+	merging sync.WaitGroup
+	done    chan struct{}
+	closed  bool
+	// Close has finished, merged channel can't be closed by it anymore
+	finished bool
+}
+
+type poolMember struct {
+	name   string
+	server string
+	client *Client
+}
+
+// Creates empty pool, options are applied to every client created by Add.
+func NewPool(opts ...Option) *Pool {
+	return &Pool{
+		opts:    opts,
+		members: make(map[string]*poolMember),
+		done:    make(chan struct{}),
+	}
+}
+
+// Creates and connects client of the account, then adds it to the pool.
+func (p *Pool) Add(account Account) (*Client, error) {
+	name := account.Name
+	if name == "" {
+		name = account.User
+	}
+	if _, found := p.Client(name); found {
+		return nil, fmt.Errorf("cwapi: account %s is already in the pool", name)
+	}
+
+	opts := append([]Option{}, p.opts...)
+	// empty server keeps WithServer or WithURL passed to NewPool
+	if account.Server != "" {
+		opts = append(opts, WithServer(account.Server))
+	}
+	opts = append(opts, account.Options...)
+
+	client, err := New(account.User, account.Password, opts...)
+	if err != nil {
+		if client != nil {
+			client.Close(context.Background())
+		}
+		return nil, err
+	}
+
+	if err := p.AddClient(name, account.Server, client); err != nil {
+		client.Close(context.Background())
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Adds already created client to the pool under given name, pool closes it on Close.
+// Empty server is taken from broker URL of the client, it stays empty for custom brokers.
+func (p *Pool) AddClient(name string, server string, client *Client) error {
+	if server == "" {
+		server = serverOfURL(client.RabbitUrl)
+	} else {
+		server = normalizeServer(server)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrClientClosed
+	}
+	if _, found := p.members[name]; found {
+		return fmt.Errorf("cwapi: account %s is already in the pool", name)
+	}
+
+	m := &poolMember{
+		name:   name,
+		server: server,
+		client: client,
+	}
+	p.members[name] = m
+	p.names = append(p.names, name)
+
+	if p.merged != nil {
+		p.mergeLocked(m)
+	}
+
+	return nil
+}
+
+// Removes account from the pool and closes its client.
+func (p *Pool) Remove(ctx context.Context, name string) error {
+	p.mu.Lock()
+	m, found := p.members[name]
+	if found {
+		delete(p.members, name)
+		for i, n := range p.names {
+			if n == name {
+				p.names = append(p.names[:i], p.names[i+1:]...)
+				break
+			}
+		}
+	}
+	p.mu.Unlock()
+
+	if !found {
+		return ErrUnknownAccount
+	}
+
+	return m.client.Close(ctx)
+}
+
+// Returns client of the account.
+func (p *Pool) Client(name string) (*Client, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	m, found := p.members[name]
+	if !found {
+		return nil, false
+	}
+	return m.client, true
+}
+
+// Returns names of all accounts in order they were added.
+func (p *Pool) Names() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return append([]string{}, p.names...)
+}
+
+// Returns names of accounts on the server: cw2, eu, cw3 or ru.
+func (p *Pool) NamesByServer(server string) []string {
+	server = normalizeServer(server)
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var names []string
+	for _, name := range p.names {
+		if p.members[name].server == server {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Returns clients of accounts on the server: cw2, eu, cw3 or ru.
+func (p *Pool) ByServer(server string) []*Client {
+	server = normalizeServer(server)
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var clients []*Client
+	for _, name := range p.names {
+		if m := p.members[name]; m.server == server {
+			clients = append(clients, m.client)
+		}
+	}
+	return clients
+}
+
+// Calls fn with client of the account.
+func (p *Pool) Do(name string, fn func(client *Client) error) error {
+	client, found := p.Client(name)
+	if !found {
+		return ErrUnknownAccount
+	}
+	return fn(client)
+}
+
+// Sends updates of every account, including added later, to one channel. Updates channels
+// of the clients are drained by the pool then, so don't read them yourself.
+// Returned channel is closed by Close, repeated calls return the same channel.
+func (p *Pool) MergeUpdates(buffer int) <-chan PoolUpdate {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.merged != nil {
+		return p.merged
+	}
+
+	p.merged = make(chan PoolUpdate, buffer)
+	if p.closed {
+		if p.finished {
+			close(p.merged)
+		}
+		return p.merged
+	}
+
+	for _, name := range p.names {
+		p.mergeLocked(p.members[name])
+	}
+
+	return p.merged
+}
+
+// Forwards member updates to merged channel until client is closed. Once pool is closing,
+// updates are dropped instead.
+func (p *Pool) mergeLocked(m *poolMember) {
+	updates := m.client.Updates
+	if updates == nil {
+		return
+	}
+
+	p.merging.Add(1)
+	go func() {
+		defer p.merging.Done()
+
+		for res := range updates {
+			select {
+			case p.merged <- PoolUpdate{Account: m.name, Server: m.server, Response: res}:
+			case <-p.done:
+			}
+		}
+	}()
+}
+
+// Returns connection state of every account.
+func (p *Pool) Health() PoolHealth {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	h := PoolHealth{
+		States: make(map[string]ConnectionState, len(p.members)),
+		Total:  len(p.members),
+	}
+	for name, m := range p.members {
+		state := m.client.State()
+		h.States[name] = state
+		if state == StateConnected {
+			h.Connected++
+		}
+	}
+	return h
+}
+
+// Closes every client of the pool and merged updates channel. Updates which weren't read from merged channel
+// by then are dropped. Returns the first error, if any.
+func (p *Pool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+
+	members := make([]*poolMember, 0, len(p.names))
+	for _, name := range p.names {
+		members = append(members, p.members[name])
+	}
+	p.mu.Unlock()
+
+	// merge goroutines stop forwarding and only drain client channels, so clients can close
+	// even if application doesn't read merged channel anymore
+	close(p.done)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(members))
+	for i, m := range members {
+		wg.Add(1)
+		go func(i int, m *poolMember) {
+			defer wg.Done()
+			errs[i] = m.client.Close(ctx)
+		}(i, m)
+	}
+	wg.Wait()
+	p.merging.Wait()
+
+	p.mu.Lock()
+	if p.merged != nil {
+		close(p.merged)
+	}
+	p.finished = true
+	p.mu.Unlock()
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("cwapi: cannot close account %s: %s", members[i].name, err)
+		}
+	}
+	return nil
+}
+
+// Returns canonical server name: cw2 for cw2 and eu, cw3 for cw3 and ru. Defaults to cw2 like WithServer does.
+func normalizeServer(server string) string {
+	switch strings.ToLower(server) {
+	case "cw3", "ru":
+		return "cw3"
+	default:
+		return "cw2"
+	}
+}
+
+// Returns canonical server name of broker URL, empty for custom brokers.
+func serverOfURL(url string) string {
+	switch {
+	case strings.Contains(url, "@api.chatwars.me:"):
+		return "cw2"
+	case strings.Contains(url, "@api.chtwrs.com:"):
+		return "cw3"
+	default:
+		return ""
+	}
+}
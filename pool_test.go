@@ -0,0 +1,112 @@
+package cwapi
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPoolMergeUpdates(t *testing.T) {
+	eu, ru := NewMemoryTransport(), NewMemoryTransport()
+	pool := NewPool(WithLogger(nil))
+	defer pool.Close(context.Background())
+
+	if _, err := pool.Add(Account{Name: "eu", User: "a", Server: "eu", Options: []Option{WithTransport(eu)}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Add(Account{Name: "ru", User: "b", Server: "ru", Options: []Option{WithTransport(ru)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := pool.NamesByServer("cw3"); len(names) != 1 || names[0] != "ru" {
+		t.Fatalf("unexpected accounts of cw3: %v", names)
+	}
+
+	updates := pool.MergeUpdates(10)
+	ru.Deliver("b_i", Response{Action: "getInfo", Result: "Ok"})
+
+	select {
+	case u := <-updates:
+		if u.Account != "ru" || u.Server != "cw3" {
+			t.Fatalf("unexpected update: %+v", u)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("update was not merged")
+	}
+
+	if h := pool.Health(); !h.Healthy() || h.Total != 2 {
+		t.Fatalf("unexpected health: %+v", h)
+	}
+}
+
+func TestPoolCloseWithUnreadMergedUpdates(t *testing.T) {
+	transport := NewMemoryTransport()
+	pool := NewPool(WithLogger(nil), WithBufferSize(StreamUpdates, 1))
+
+	if _, err := pool.Add(Account{User: "a", Options: []Option{WithTransport(transport)}}); err != nil {
+		t.Fatal(err)
+	}
+	pool.MergeUpdates(0)
+
+	for i := 0; i < 5; i++ {
+		transport.Deliver("a_i", Response{Action: "getInfo", Result: "Ok"})
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- pool.Close(context.Background())
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Pool.Close hangs")
+	}
+}
+
+func TestPoolKeepsURLOptionWithoutServer(t *testing.T) {
+	pool := NewPool(WithLogger(nil), WithURL("amqps://%s:%s@localhost:5673/"))
+	defer pool.Close(context.Background())
+
+	transport := NewMemoryTransport()
+	client, err := pool.Add(Account{User: "a", Password: "p", Options: []Option{WithTransport(transport)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.RabbitUrl != "amqps://a:p@localhost:5673/" {
+		t.Fatalf("pool URL was overridden: %s", client.RabbitUrl)
+	}
+}
+
+func TestPoolLabelsAccountWithoutServerByURL(t *testing.T) {
+	pool := NewPool(WithLogger(nil), WithServer("cw3"))
+	defer pool.Close(context.Background())
+
+	transport := NewMemoryTransport()
+	if _, err := pool.Add(Account{User: "a", Options: []Option{WithTransport(transport)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := pool.NamesByServer("ru"); len(names) != 1 || names[0] != "a" {
+		t.Fatalf("unexpected accounts of cw3: %v", names)
+	}
+	if names := pool.NamesByServer("cw2"); len(names) != 0 {
+		t.Fatalf("unexpected accounts of cw2: %v", names)
+	}
+
+	updates := pool.MergeUpdates(10)
+	transport.Deliver("a_i", Response{Action: "getInfo", Result: "Ok"})
+
+	select {
+	case u := <-updates:
+		if u.Server != "cw3" {
+			t.Fatalf("unexpected update: %+v", u)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("update was not merged")
+	}
+}
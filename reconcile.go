@@ -0,0 +1,163 @@
+package cwapi
+
+import (
+	"context"
+	"time"
+)
+
+// Chat Wars pouch is worth 100 gold.
+const goldPerPouch = 100
+
+// Converts amount to gold, e.g. {"gold": 5, "pouches": 3} is 305.
+func inGold(amount map[string]int) int {
+	return amount["gold"] + amount["pouches"]*goldPerPouch
+}
+
+// Result of single reconciliation, see Reconciler.
+type ReconcileReport struct {
+	Time time.Time
+	// Application balance in gold reported by getInfo
+	Balance int
+	// Balance in gold according to baseline and the ledger
+	Expected int
+	// Balance minus Expected
+	Discrepancy int
+	// Balance is below Reconciler.LowBalance
+	LowBalance bool
+}
+
+// Reports whether balance differs from expected one more than tolerance allows.
+func (r ReconcileReport) Discrepant(tolerance int) bool {
+	return r.Discrepancy > tolerance || r.Discrepancy < -tolerance
+}
+
+// Periodically compares application balance from getInfo with the ledger:
+// expected balance is Baseline plus successful pays minus successful payouts recorded since Since.
+//
+//	r := cwapi.NewReconciler(client, ledger, balance, time.Now())
+//	r.LowBalance = 1000
+//	r.Alert = func(report cwapi.ReconcileReport) {
+//		log.Printf("balance %d, expected %d", report.Balance, report.Expected)
+//	}
+//
+//	go r.Run(ctx)
+//
+// Amounts in pouches are converted to gold, one pouch is 100 gold. Responses are paired with requests
+// by transaction ID and counted with the requested amount. Fee of pay is charged to the user on top
+// of the amount, it's a part of debit, so application is credited with the amount only and neither
+// fee nor debit is counted.
+type Reconciler struct {
+	client *Client
+	ledger *Ledger
+
+	// Balance in gold at Since
+	Baseline int
+	Since    time.Time
+	// Defaults to 1 minute
+	Interval time.Duration
+	// Discrepancy in gold which is not reported
+	Tolerance int
+	// Balance in gold below which report is sent even without discrepancy, zero disables it
+	LowBalance int
+	// Called with reports which have discrepancy or low balance
+	Alert func(report ReconcileReport)
+}
+
+// Creates reconciler, baseline is application balance in gold at since.
+func NewReconciler(client *Client, ledger *Ledger, baseline int, since time.Time) *Reconciler {
+	return &Reconciler{
+		client:   client,
+		ledger:   ledger,
+		Baseline: baseline,
+		Since:    since,
+		Interval: time.Minute,
+	}
+}
+
+// Requests balance and compares it with the ledger. Alert is called if needed.
+func (r *Reconciler) Check(ctx context.Context) (ReconcileReport, error) {
+	res, err := r.client.GetInfoSyncContext(ctx)
+	if err != nil {
+		return ReconcileReport{}, err
+	}
+
+	// request may be recorded before Since while its response after
+	entries, err := r.ledger.Entries(LedgerQuery{})
+	if err != nil {
+		return ReconcileReport{}, err
+	}
+
+	// amounts are taken from requests, response entry has it only if it was paired when recorded
+	requested := make(map[string]map[string]int)
+	for _, e := range entries {
+		if e.Type == LedgerRequest && e.TransactionID != "" {
+			requested[e.TransactionID] = e.Amount
+		}
+	}
+
+	expected := r.Baseline
+	for _, e := range entries {
+		if e.Type != LedgerResponse || e.Result != string(Ok) || e.Time.Before(r.Since) {
+			continue
+		}
+
+		amount := inGold(e.Amount)
+		if req, found := requested[e.TransactionID]; found {
+			amount = inGold(req)
+		}
+		switch e.Action {
+		case "pay":
+			expected += amount
+		case "payout":
+			expected -= amount
+		}
+	}
+
+	report := ReconcileReport{
+		Time:     time.Now(),
+		Balance:  res.Payload.ResGetInfo.Balance,
+		Expected: expected,
+	}
+	report.Discrepancy = report.Balance - report.Expected
+	report.LowBalance = r.LowBalance > 0 && report.Balance < r.LowBalance
+
+	if report.Discrepant(r.Tolerance) {
+		r.client.logger.Warn("balance discrepancy",
+			"balance", report.Balance,
+			"expected", report.Expected,
+			"discrepancy", report.Discrepancy,
+		)
+	}
+
+	if r.Alert != nil && (report.Discrepant(r.Tolerance) || report.LowBalance) {
+		r.Alert(report)
+	}
+
+	return report, nil
+}
+
+// Checks balance every Interval until ctx is done or client is closed. Failed checks are logged and skipped.
+func (r *Reconciler) Run(ctx context.Context) error {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Check(ctx); err != nil {
+			if err == ErrClientClosed {
+				return err
+			}
+			r.client.logger.Warn("reconciliation failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
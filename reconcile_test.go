@@ -0,0 +1,52 @@
+package cwapi
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReconcilerPairsResponsesWithRequests(t *testing.T) {
+	ledger, cleanup := openTestLedger(t)
+	defer cleanup()
+
+	since := time.Now()
+	entries := []LedgerEntry{
+		// requested before since, paid after
+		{Time: since.Add(-time.Second), Type: LedgerRequest, Action: "pay", TransactionID: "tx1", Amount: map[string]int{"pouches": 3}},
+		// response recorded without request details, fee and debit don't change the balance
+		{Time: since.Add(time.Second), Type: LedgerResponse, Action: "pay", TransactionID: "tx1", Result: string(Ok), Fee: map[string]int{"gold": 5}, Debit: map[string]int{"gold": 305}},
+		{Time: since.Add(time.Second), Type: LedgerRequest, Action: "payout", TransactionID: "tx2", Amount: map[string]int{"pouches": 1}},
+		{Time: since.Add(time.Second), Type: LedgerResponse, Action: "payout", TransactionID: "tx2", Result: string(Ok)},
+		{Time: since.Add(time.Second), Type: LedgerRequest, Action: "payout", TransactionID: "tx3", Amount: map[string]int{"pouches": 1}},
+		{Time: since.Add(time.Second), Type: LedgerResponse, Action: "payout", TransactionID: "tx3", Result: string(InsufficientFunds)},
+	}
+	for _, e := range entries {
+		if err := ledger.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client, transport := newTestClient(t)
+	defer closeTestClient(client)
+
+	go func() {
+		req := <-transport.Requests()
+		transport.Reply("login", req, Ok, ResGetInfo{Balance: 1150})
+	}()
+
+	r := NewReconciler(client, ledger, 1000, since)
+	var alerted bool
+	r.Alert = func(ReconcileReport) { alerted = true }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	report, err := r.Check(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Expected != 1200 || report.Discrepancy != -50 || !alerted {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
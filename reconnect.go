@@ -0,0 +1,140 @@
+package cwapi
+
+import (
+	"errors"
+	"time"
+)
+
+type ConnectionState string
+
+const (
+	// Connection is established and all consumers are running
+	StateConnected ConnectionState = "connected"
+	// Connection or one of channels was closed, reconnection is pending
+	StateDisconnected ConnectionState = "disconnected"
+	// New reconnection attempt has been started
+	StateReconnecting ConnectionState = "reconnecting"
+	// Client was closed or gave up reconnecting, no more events will be sent
+	StateClosed ConnectionState = "closed"
+)
+
+// Connection state change, Attempt is set for reconnection events, Err contains the reason if any.
+type ConnectionEvent struct {
+	State   ConnectionState
+	Attempt int
+	Err     error
+}
+
+// Describes how often client tries to reconnect.
+// Delay grows from InitialInterval by Multiplier up to MaxInterval,
+// every delay is randomized by +/- Jitter fraction. Zero MaxAttempts means retrying forever.
+type ReconnectPolicy struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	Jitter          float64
+	MaxAttempts     int
+}
+
+// Policy used by default, see WithReconnectPolicy.
+var DefaultReconnectPolicy = ReconnectPolicy{
+	InitialInterval: 500 * time.Millisecond,
+	MaxInterval:     30 * time.Second,
+	Multiplier:      2,
+	Jitter:          0.2,
+}
+
+// Returns delay before given (starting from 1) attempt.
+func (p ReconnectPolicy) backoff(attempt int) time.Duration {
+	return exponentialBackoff(p.InitialInterval, p.MaxInterval, p.Multiplier, p.Jitter, attempt)
+}
+
+// Registers listener for connection state changes.
+// Events are sent without blocking, so use buffered channel or events will be dropped.
+func (c *Client) NotifyConnectionState(ch chan ConnectionEvent) chan ConnectionEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stateListeners = append(c.stateListeners, ch)
+	return ch
+}
+
+// Returns current connection state.
+func (c *Client) State() ConnectionState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.state
+}
+
+func (c *Client) setState(state ConnectionState, attempt int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.state = state
+
+	event := ConnectionEvent{
+		State:   state,
+		Attempt: attempt,
+		Err:     err,
+	}
+	for _, ch := range c.stateListeners {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Watches current session, reconnects and resubscribes every active stream
+// once it is closed. Runs until client is closed or reconnection attempts are exhausted.
+func (c *Client) supervise() {
+	for {
+		var reason error
+		select {
+		case <-c.done:
+			return
+		case reason = <-c.currentSession().NotifyClose():
+		}
+
+		// closed by CloseConnection, nothing to do here
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+
+		c.logger.Warn("connection closed", "error", reason)
+		c.setState(StateDisconnected, 0, reason)
+
+		if !c.reconnect() {
+			return
+		}
+	}
+}
+
+// Tries to connect again following reconnection policy.
+// Returns false if client was closed or attempts are exhausted.
+func (c *Client) reconnect() bool {
+	for attempt := 1; c.reconnectPolicy.MaxAttempts == 0 || attempt <= c.reconnectPolicy.MaxAttempts; attempt++ {
+		select {
+		case <-c.done:
+			return false
+		case <-time.After(c.reconnectPolicy.backoff(attempt)):
+		}
+
+		c.setState(StateReconnecting, attempt, nil)
+
+		if err := c.connect(); err != nil {
+			c.logger.Warn("reconnect unsuccessful", "attempt", attempt, "error", err)
+			continue
+		}
+
+		c.logger.Info("reconnect successful", "attempt", attempt)
+		c.setState(StateConnected, attempt, nil)
+		return true
+	}
+
+	c.setState(StateClosed, c.reconnectPolicy.MaxAttempts, errors.New("reconnection attempts exhausted"))
+	return false
+}
@@ -0,0 +1,165 @@
+package cwapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Declares action of Chat Wars API, see RegisterAction.
+type ActionSpec struct {
+	Name ActionEnum
+	// Prototype of request payload, e.g. ReqNewAction{}. Nil if action has no payload
+	Request interface{}
+	// Prototype of response payload, e.g. ResNewAction{}. Response.Decoded holds pointer to such value
+	Response interface{}
+	// Returns user the response belongs to, so Sync requests can be matched. Nil means userId field of the payload
+	UserID func(res *Response) int
+}
+
+// Registered actions, built-in ones are registered in init.
+var actions = struct {
+	sync.RWMutex
+	specs map[ActionEnum]ActionSpec
+}{
+	specs: make(map[ActionEnum]ActionSpec),
+}
+
+func init() {
+	RegisterAction(ActionSpec{Name: CreateAuthCode, Request: reqCreateAuthCode{}, Response: ResCreateAuthCode{}})
+	RegisterAction(ActionSpec{Name: GrantToken, Request: reqGrantToken{}, Response: ResGrantToken{}})
+	RegisterAction(ActionSpec{Name: AuthAdditionalOperation, Request: reqAuthAdditionalOperation{}, Response: ResAuthAdditionalOperation{}})
+	RegisterAction(ActionSpec{Name: GrantAdditionalOperation, Request: reqGrantAdditionalOperation{}, Response: ResGrantAdditionalOperation{}})
+	RegisterAction(ActionSpec{Name: AuthorizePayment, Request: reqAuthorizePayment{}, Response: ResAuthorizePayment{}})
+	RegisterAction(ActionSpec{Name: Pay, Request: reqPay{}, Response: ResPay{}})
+	RegisterAction(ActionSpec{Name: Payout, Request: reqPayout{}, Response: ResPayout{}})
+	RegisterAction(ActionSpec{Name: GetInfo, Response: ResGetInfo{}})
+	RegisterAction(ActionSpec{Name: ViewCraftbook, Response: ResViewCraftbook{}})
+	RegisterAction(ActionSpec{Name: RequestProfile, Response: ResRequestProfile{}})
+	RegisterAction(ActionSpec{Name: RequestBasicInfo, Response: ResRequestBasicInfo{}})
+	RegisterAction(ActionSpec{Name: RequestGearInfo, Response: ResRequestGearInfo{}})
+	RegisterAction(ActionSpec{Name: RequestStock, Response: ResRequestStock{}})
+	RegisterAction(ActionSpec{Name: GuildInfo, Response: ResGuildInfo{}})
+	RegisterAction(ActionSpec{Name: WantToBuy, Request: reqWantToBuy{}, Response: ResWantToBuy{}})
+}
+
+// Registers action, so its responses are decoded into Response.Decoded and it can be sent with Client.Do:
+//
+//	type ReqNewAction struct {
+//		UserID int `json:"userId"`
+//	}
+//
+//	type ResNewAction struct {
+//		UserID int    `json:"userId"`
+//		Value  string `json:"value"`
+//	}
+//
+//	cwapi.RegisterAction(cwapi.ActionSpec{
+//		Name:     "newAction",
+//		Request:  ReqNewAction{},
+//		Response: ResNewAction{},
+//	})
+//
+// Call it from init, before any client is created. It panics if action is already registered.
+func RegisterAction(spec ActionSpec) {
+	if spec.Name == "" {
+		panic("cwapi: action name is empty")
+	}
+
+	actions.Lock()
+	defer actions.Unlock()
+
+	if _, found := actions.specs[spec.Name]; found {
+		panic(fmt.Sprintf("cwapi: action %s is already registered", spec.Name))
+	}
+	actions.specs[spec.Name] = spec
+}
+
+// Returns registered action.
+func LookupAction(name ActionEnum) (ActionSpec, bool) {
+	actions.RLock()
+	defer actions.RUnlock()
+
+	spec, found := actions.specs[name]
+	return spec, found
+}
+
+// Decodes payload into new value of the response type, nil if it has none.
+func (spec ActionSpec) decode(payload json.RawMessage) (interface{}, error) {
+	if spec.Response == nil {
+		return nil, nil
+	}
+
+	v := reflect.New(reflect.TypeOf(spec.Response))
+	if err := json.Unmarshal(payload, v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// Returns user the response belongs to.
+func (spec ActionSpec) userID(res *Response) int {
+	if spec.UserID != nil {
+		return spec.UserID(res)
+	}
+	return payloadUserID(res.RawPayload)
+}
+
+// Sets embedded field of built-in response type, e.g. Payload.ResPay for *ResPay value.
+func (payload *resPayload) set(decoded interface{}) {
+	v := reflect.ValueOf(decoded)
+	p := reflect.ValueOf(payload).Elem()
+	for i := 0; i < p.NumField(); i++ {
+		if p.Field(i).Type() == v.Type() {
+			p.Field(i).Set(v)
+			return
+		}
+	}
+}
+
+// Sends registered action and waits for the response until ctx is done. Payload must be of the request type
+// of the action, nil if it has none. Response payload is decoded into Response.Decoded:
+//
+//	res, err := client.Do(ctx, "newAction", token, ReqNewAction{UserID: userID}, userID)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	log.Println(res.Decoded.(*ResNewAction).Value)
+//
+// UserID is used to match the response if server doesn't echo correlation ID, like in Sync methods.
+// Zero userID is taken from userId field of the payload, if any.
+func (c *Client) Do(ctx context.Context, action ActionEnum, token string, payload interface{}, userID int) (*Response, error) {
+	spec, found := LookupAction(action)
+	if !found {
+		return nil, fmt.Errorf("cwapi: action %s is not registered", action)
+	}
+	if spec.Request != nil && reflect.TypeOf(payload) != reflect.TypeOf(spec.Request) {
+		return nil, fmt.Errorf("cwapi: action %s expects %T payload, got %T", action, spec.Request, payload)
+	}
+
+	req := &Request{
+		Action: string(action),
+		Token:  token,
+	}
+
+	if payload != nil {
+		p, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		req.Payload = p
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if userID == 0 {
+		userID = payloadUserID(req.Payload)
+	}
+
+	return c.makeSyncRequest(ctx, req.Action, body, userID)
+}
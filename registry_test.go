@@ -0,0 +1,43 @@
+package cwapi
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDoTokenOnlyActionWithoutCorrelationEcho(t *testing.T) {
+	client, transport := newTestClient(t)
+	defer closeTestClient(client)
+
+	go func() {
+		<-transport.Requests()
+		transport.DeliverRaw("login_i", "", []byte(`{"action":"requestStock","result":"Ok","payload":{"userId":7,"stock":{"01":3}}}`))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	res, err := client.Do(ctx, RequestStock, "tok", nil, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stock := res.Decoded.(*ResRequestStock); stock.Stock["01"] != 3 {
+		t.Fatalf("unexpected stock: %v", stock.Stock)
+	}
+	if res.Payload.ResRequestStock == nil {
+		t.Fatal("built-in payload field is not set")
+	}
+}
+
+func TestDoChecksPayloadType(t *testing.T) {
+	client, _ := newTestClient(t)
+	defer closeTestClient(client)
+
+	if _, err := client.Do(context.Background(), Pay, "tok", 5, 7); err == nil {
+		t.Fatal("payload of wrong type was accepted")
+	}
+	if _, err := client.Do(context.Background(), ActionEnum("notRegistered"), "tok", nil, 7); err == nil {
+		t.Fatal("unregistered action was accepted")
+	}
+}
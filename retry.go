@@ -0,0 +1,96 @@
+package cwapi
+
+import (
+	"encoding/json"
+	"math/rand"
+	"time"
+)
+
+// Describes how requests are repeated on failures.
+// Request is made up to MaxAttempts times in total, delay grows from InitialInterval by Multiplier
+// up to MaxInterval, every delay is randomized by +/- Jitter fraction.
+//
+// Retryable decides which errors qualify, by default those are TryAgain result and ErrClosed.
+// Sync methods are retried within their context only, set Async to retry publish failures of async methods too.
+//
+// Pay and Payout are not idempotent, so they are retried only when transaction ID is set,
+// and exactly the same request (with the same transaction ID) is sent again.
+type RetryPolicy struct {
+	MaxAttempts     int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	Jitter          float64
+	Retryable       func(err error) bool
+	Async           bool
+}
+
+// Sensible policy to start with, pass it to WithRetryPolicy. Client doesn't retry anything by default.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:     3,
+	InitialInterval: 500 * time.Millisecond,
+	MaxInterval:     5 * time.Second,
+	Multiplier:      2,
+	Jitter:          0.2,
+}
+
+// Actions which must not be repeated with different transaction ID.
+var nonIdempotentActions = map[string]bool{
+	string(Pay):    true,
+	string(Payout): true,
+}
+
+// Reports whether error qualifies for retry.
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err) || err == ErrClosed
+}
+
+// Reports whether request can be repeated after given (starting from 1) attempt failed with err.
+func (p RetryPolicy) shouldRetry(attempt int, err error) bool {
+	return attempt < p.MaxAttempts && p.retryable(err)
+}
+
+// Returns delay after given (starting from 1) attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	return exponentialBackoff(p.InitialInterval, p.MaxInterval, p.Multiplier, p.Jitter, attempt)
+}
+
+// Reports whether request body can be sent again.
+func (p RetryPolicy) allows(req []byte) bool {
+	if p.MaxAttempts <= 1 {
+		return false
+	}
+
+	var r struct {
+		Action  string `json:"action"`
+		Payload struct {
+			TransactionID string `json:"transactionId"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(req, &r); err != nil {
+		return false
+	}
+
+	return !nonIdempotentActions[r.Action] || r.Payload.TransactionID != ""
+}
+
+// Returns delay which grows from initial by multiplier up to max, randomized by +/- jitter fraction.
+func exponentialBackoff(initial time.Duration, max time.Duration, multiplier float64, jitter float64, attempt int) time.Duration {
+	delay := float64(initial)
+	for i := 1; i < attempt; i++ {
+		delay *= multiplier
+		if max > 0 && delay > float64(max) {
+			delay = float64(max)
+			break
+		}
+	}
+
+	if jitter > 0 {
+		delay += delay * jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
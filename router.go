@@ -0,0 +1,203 @@
+package cwapi
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Handles single response.
+type HandlerFunc func(res *Response) error
+
+// Wraps handler, e.g. to log, recover or measure it.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Dispatches responses to handlers registered per action, so you don't need to switch on GetActionEnum:
+//
+//	router := cwapi.NewRouter()
+//	router.HandleRequestStock(func(res *cwapi.Response, stock *cwapi.ResRequestStock) error {
+//		log.Println(stock.UserID, stock.Stock)
+//		return nil
+//	})
+//	router.Fallback(func(res *cwapi.Response) error {
+//		log.Println("unhandled action", res.Action)
+//		return nil
+//	})
+//
+//	client, err := cwapi.New("login", "password", cwapi.WithRouter(router))
+//
+// Handlers are called one by one from the update consumer, so don't block in them for long.
+// Errors returned by handlers and their panics are logged. Handlers can be registered at any time.
+type Router struct {
+	mu         sync.RWMutex
+	handlers   map[ActionEnum]HandlerFunc
+	middleware []Middleware
+	fallback   HandlerFunc
+}
+
+// Creates empty router.
+func NewRouter() *Router {
+	return &Router{
+		handlers: make(map[ActionEnum]HandlerFunc),
+	}
+}
+
+// Registers handler for the action, it replaces previously registered one.
+// Action may be unknown to this library, e.g. ActionEnum("newAction"), see Response.DecodePayload.
+func (r *Router) Handle(action ActionEnum, handler HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers[action] = handler
+}
+
+// Adds middleware applied to every handler including fallback. First added is the outermost one.
+func (r *Router) Use(middleware ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Registers handler for unknown actions and actions without handler.
+func (r *Router) Fallback(handler HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fallback = handler
+}
+
+// Calls handler registered for the response action. Panic of handler is returned as error.
+func (r *Router) Dispatch(res *Response) (err error) {
+	r.mu.RLock()
+	handler, found := r.handlers[ActionEnum(res.Action)]
+	if !found {
+		// handler registered for UnknownAction
+		handler, found = r.handlers[res.GetActionEnum()]
+	}
+	if !found {
+		handler = r.fallback
+	}
+	middleware := r.middleware
+	r.mu.RUnlock()
+
+	if handler == nil {
+		return nil
+	}
+
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("cwapi: handler of %s panicked: %v", res.Action, p)
+		}
+	}()
+
+	return handler(res)
+}
+
+// Registers typed handler for createAuthCode action.
+func (r *Router) HandleCreateAuthCode(handler func(res *Response, payload *ResCreateAuthCode) error) {
+	r.Handle(CreateAuthCode, func(res *Response) error {
+		return handler(res, res.Payload.ResCreateAuthCode)
+	})
+}
+
+// Registers typed handler for grantToken action.
+func (r *Router) HandleGrantToken(handler func(res *Response, payload *ResGrantToken) error) {
+	r.Handle(GrantToken, func(res *Response) error {
+		return handler(res, res.Payload.ResGrantToken)
+	})
+}
+
+// Registers typed handler for authAdditionalOperation action.
+func (r *Router) HandleAuthAdditionalOperation(handler func(res *Response, payload *ResAuthAdditionalOperation) error) {
+	r.Handle(AuthAdditionalOperation, func(res *Response) error {
+		return handler(res, res.Payload.ResAuthAdditionalOperation)
+	})
+}
+
+// Registers typed handler for grantAdditionalOperation action.
+func (r *Router) HandleGrantAdditionalOperation(handler func(res *Response, payload *ResGrantAdditionalOperation) error) {
+	r.Handle(GrantAdditionalOperation, func(res *Response) error {
+		return handler(res, res.Payload.ResGrantAdditionalOperation)
+	})
+}
+
+// Registers typed handler for authorizePayment action.
+func (r *Router) HandleAuthorizePayment(handler func(res *Response, payload *ResAuthorizePayment) error) {
+	r.Handle(AuthorizePayment, func(res *Response) error {
+		return handler(res, res.Payload.ResAuthorizePayment)
+	})
+}
+
+// Registers typed handler for pay action.
+func (r *Router) HandlePay(handler func(res *Response, payload *ResPay) error) {
+	r.Handle(Pay, func(res *Response) error {
+		return handler(res, res.Payload.ResPay)
+	})
+}
+
+// Registers typed handler for payout action.
+func (r *Router) HandlePayout(handler func(res *Response, payload *ResPayout) error) {
+	r.Handle(Payout, func(res *Response) error {
+		return handler(res, res.Payload.ResPayout)
+	})
+}
+
+// Registers typed handler for getInfo action.
+func (r *Router) HandleGetInfo(handler func(res *Response, payload *ResGetInfo) error) {
+	r.Handle(GetInfo, func(res *Response) error {
+		return handler(res, res.Payload.ResGetInfo)
+	})
+}
+
+// Registers typed handler for viewCraftbook action.
+func (r *Router) HandleViewCraftbook(handler func(res *Response, payload *ResViewCraftbook) error) {
+	r.Handle(ViewCraftbook, func(res *Response) error {
+		return handler(res, res.Payload.ResViewCraftbook)
+	})
+}
+
+// Registers typed handler for requestProfile action.
+func (r *Router) HandleRequestProfile(handler func(res *Response, payload *ResRequestProfile) error) {
+	r.Handle(RequestProfile, func(res *Response) error {
+		return handler(res, res.Payload.ResRequestProfile)
+	})
+}
+
+// Registers typed handler for requestBasicInfo action.
+func (r *Router) HandleRequestBasicInfo(handler func(res *Response, payload *ResRequestBasicInfo) error) {
+	r.Handle(RequestBasicInfo, func(res *Response) error {
+		return handler(res, res.Payload.ResRequestBasicInfo)
+	})
+}
+
+// Registers typed handler for requestGearInfo action.
+func (r *Router) HandleRequestGearInfo(handler func(res *Response, payload *ResRequestGearInfo) error) {
+	r.Handle(RequestGearInfo, func(res *Response) error {
+		return handler(res, res.Payload.ResRequestGearInfo)
+	})
+}
+
+// Registers typed handler for requestStock action.
+func (r *Router) HandleRequestStock(handler func(res *Response, payload *ResRequestStock) error) {
+	r.Handle(RequestStock, func(res *Response) error {
+		return handler(res, res.Payload.ResRequestStock)
+	})
+}
+
+// Registers typed handler for guildInfo action.
+func (r *Router) HandleGuildInfo(handler func(res *Response, payload *ResGuildInfo) error) {
+	r.Handle(GuildInfo, func(res *Response) error {
+		return handler(res, res.Payload.ResGuildInfo)
+	})
+}
+
+// Registers typed handler for wantToBuy action.
+func (r *Router) HandleWantToBuy(handler func(res *Response, payload *ResWantToBuy) error) {
+	r.Handle(WantToBuy, func(res *Response) error {
+		return handler(res, res.Payload.ResWantToBuy)
+	})
+}
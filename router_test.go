@@ -0,0 +1,33 @@
+package cwapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRouterSurvivesPanicAndLateRegistration(t *testing.T) {
+	router := NewRouter()
+	client, transport := newTestClient(t, WithRouter(router))
+	defer closeTestClient(client)
+
+	handled := make(chan int, 1)
+	router.HandleRequestStock(func(res *Response, stock *ResRequestStock) error {
+		if stock.UserID == 1 {
+			panic("boom")
+		}
+		handled <- stock.UserID
+		return nil
+	})
+
+	transport.DeliverRaw("login_i", "", []byte(`{"action":"requestStock","result":"Ok","payload":{"userId":1}}`))
+	transport.DeliverRaw("login_i", "", []byte(`{"action":"requestStock","result":"Ok","payload":{"userId":2}}`))
+
+	select {
+	case userID := <-handled:
+		if userID != 2 {
+			t.Fatalf("unexpected user %d", userID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("consumer stopped after handler panic")
+	}
+}
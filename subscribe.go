@@ -0,0 +1,248 @@
+package cwapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Decodes delivery body into value sent to the subscription channel.
+type DecodeFunc func(body []byte) (interface{}, error)
+
+// Returns DecodeFunc which unmarshals JSON into new value of the same type as prototype,
+// e.g. DecodeJSON(Deal{}) produces Deal values and DecodeJSON([]YellowPage{}) produces []YellowPage.
+func DecodeJSON(prototype interface{}) DecodeFunc {
+	t := reflect.TypeOf(prototype)
+	return func(body []byte) (interface{}, error) {
+		v := reflect.New(t)
+		err := json.Unmarshal(body, v.Interface())
+		return v.Elem().Interface(), err
+	}
+}
+
+// Decodes nothing, value is json.RawMessage with the body.
+func decodeRaw(body []byte) (interface{}, error) {
+	return json.RawMessage(body), nil
+}
+
+// Configures subscription made by Subscribe.
+type SubscribeOptions struct {
+	// Size of channel buffer, defaults to buffer size of the stream, see WithBufferSize
+	Buffer int
+	// Defaults to json.RawMessage values
+	Decode DecodeFunc
+	// Maximum number of unacknowledged deliveries for SubscribeManual, defaults to Buffer
+	Prefetch int
+}
+
+// Decoded delivery which application acknowledges itself, see SubscribeManual.
+type Envelope struct {
+	Stream   Stream
+	Value    interface{}
+	Delivery Delivery
+}
+
+// Acknowledges delivery, broker forgets about it.
+func (e Envelope) Ack() error {
+	return e.Delivery.Ack(false)
+}
+
+// Negatively acknowledges delivery, requeue puts it back to the queue.
+func (e Envelope) Nack(requeue bool) error {
+	return e.Delivery.Nack(false, requeue)
+}
+
+// Rejects delivery, requeue puts it back to the queue.
+func (e Envelope) Reject(requeue bool) error {
+	return e.Delivery.Reject(requeue)
+}
+
+// Subscribes to any public exchange queue of your application, including those this library doesn't know yet:
+//
+//	deals, err := client.Subscribe(cwapi.StreamDeals, cwapi.SubscribeOptions{
+//		Decode: cwapi.DecodeJSON(cwapi.Deal{}),
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	for v := range deals {
+//		deal := v.(cwapi.Deal)
+//		log.Println(deal.Item)
+//	}
+//
+// Stream is a queue suffix, so Stream("new_exchange") consumes "login_new_exchange" queue.
+// Subscription survives reconnects, channel is closed by Close.
+func (c *Client) Subscribe(stream Stream, opts SubscribeOptions) (<-chan interface{}, error) {
+	if opts.Buffer == 0 {
+		opts.Buffer = c.bufferSizes[stream]
+	}
+	if opts.Decode == nil {
+		opts.Decode = decodeRaw
+	}
+
+	ch := make(chan interface{}, opts.Buffer)
+	err := c.subscribe(&subscription{
+		stream: stream,
+		decode: opts.Decode,
+		ch:     ch,
+		close: func() {
+			close(ch)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ch, nil
+}
+
+// Same as Subscribe, but for at-least-once processing: deliveries are acknowledged by application,
+// not by the library. Ack envelope once the value is safely processed, unacknowledged deliveries
+// are redelivered after reconnect or restart:
+//
+//	deals, err := client.SubscribeManual(cwapi.StreamDeals, cwapi.SubscribeOptions{
+//		Decode:   cwapi.DecodeJSON(cwapi.Deal{}),
+//		Prefetch: 10,
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	for e := range deals {
+//		if err := store(e.Value.(cwapi.Deal)); err != nil {
+//			e.Nack(true)
+//			continue
+//		}
+//		e.Ack()
+//	}
+//
+// Consumer prefetch limits number of unacknowledged deliveries. Overflow policy is not applied,
+// the library always waits until application reads the channel.
+func (c *Client) SubscribeManual(stream Stream, opts SubscribeOptions) (<-chan Envelope, error) {
+	if opts.Buffer == 0 {
+		opts.Buffer = c.bufferSizes[stream]
+	}
+	if opts.Decode == nil {
+		opts.Decode = decodeRaw
+	}
+	if opts.Prefetch == 0 {
+		opts.Prefetch = opts.Buffer
+	}
+	if opts.Prefetch == 0 {
+		opts.Prefetch = 1
+	}
+
+	ch := make(chan Envelope, opts.Buffer)
+	err := c.subscribe(&subscription{
+		stream:    stream,
+		decode:    opts.Decode,
+		ch:        ch,
+		manualAck: true,
+		prefetch:  opts.Prefetch,
+		close: func() {
+			close(ch)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ch, nil
+}
+
+// Consumer of one public exchange queue.
+type subscription struct {
+	stream Stream
+	decode DecodeFunc
+	// Application channel
+	ch interface{}
+	// Closes application channel
+	close func()
+	sink  *sink
+	// Application acknowledges Envelope values itself
+	manualAck bool
+	prefetch  int
+}
+
+// Registers subscription, so it's restarted after reconnect, and starts it. Nothing is registered
+// if it can't be started. Subscribing is serialized with restarting consumers after reconnect,
+// so concurrent calls can't register the same stream twice and consumer is never started twice.
+func (c *Client) subscribe(s *subscription) error {
+	c.subscribeMu.Lock()
+	defer c.subscribeMu.Unlock()
+
+	if c.subscribed(s.stream) {
+		return errAlreadySubscribed(s.stream)
+	}
+
+	policy := c.overflowPolicies[s.stream]
+	if s.manualAck {
+		// dropping or spilling would break at-least-once delivery
+		policy = OverflowBlock
+	}
+
+	sink, err := c.newSink(s.stream, s.ch, s.decode, policy)
+	if err != nil {
+		return err
+	}
+	s.sink = sink
+
+	if err := c.startSubscription(s); err != nil {
+		c.removeSink(sink)
+		return err
+	}
+
+	c.mu.Lock()
+	if c.subscriptions == nil {
+		c.subscriptions = make(map[Stream]*subscription)
+	}
+	c.subscriptions[s.stream] = s
+	c.mu.Unlock()
+
+	return c.startSink(sink)
+}
+
+func errAlreadySubscribed(stream Stream) error {
+	return fmt.Errorf("cwapi: already subscribed to %s", stream)
+}
+
+func (c *Client) subscribed(stream Stream) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, found := c.subscriptions[stream]
+	return found
+}
+
+func (c *Client) startSubscription(s *subscription) error {
+	updates, err := c.currentSession().Consume(fmt.Sprintf("%s_%s", c.User, s.stream), false, s.prefetch)
+	if err != nil {
+		return err
+	}
+
+	return c.goConsume(func() {
+		for update := range updates {
+			res, err := s.decode(update.Body)
+			if err != nil {
+				c.handlePoison(s.stream, update.Body, err)
+				update.Ack(false)
+				continue
+			}
+
+			if s.manualAck {
+				if !s.sink.put(Envelope{s.stream, res, update}, update.Body, c.abort) {
+					update.Nack(false, true)
+				}
+				continue
+			}
+
+			if s.sink.put(res, update.Body, c.abort) {
+				update.Ack(false)
+			} else {
+				// client is closing and nobody reads the stream, give it back to the broker
+				update.Nack(false, true)
+			}
+		}
+	})
+}
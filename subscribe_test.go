@@ -0,0 +1,133 @@
+package cwapi
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInitTwiceKeepsLiveChannel(t *testing.T) {
+	client, transport := newTestClient(t)
+	defer closeTestClient(client)
+
+	if err := client.InitDeals(); err != nil {
+		t.Fatal(err)
+	}
+	deals := client.Deals
+
+	if err := client.InitDeals(); err == nil {
+		t.Fatal("second InitDeals should fail")
+	}
+	if client.Deals != deals {
+		t.Fatal("Deals channel was replaced")
+	}
+
+	transport.Deliver("login_deals", Deal{Item: "Thread"})
+	select {
+	case deal := <-client.Deals:
+		if deal.Item != "Thread" {
+			t.Fatalf("unexpected deal: %+v", deal)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("deal was not delivered")
+	}
+}
+
+func TestSubscribeDecodesCustomStream(t *testing.T) {
+	client, transport := newTestClient(t)
+	defer closeTestClient(client)
+
+	items, err := client.Subscribe(Stream("new_exchange"), SubscribeOptions{Decode: DecodeJSON(Offer{})})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport.Deliver("login_new_exchange", Offer{Item: "Pelt", Quantity: 2})
+	select {
+	case v := <-items:
+		if offer := v.(Offer); offer.Item != "Pelt" || offer.Quantity != 2 {
+			t.Fatalf("unexpected offer: %+v", offer)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("offer was not delivered")
+	}
+}
+
+// Fails consuming missing queue like broker does.
+type missingQueueTransport struct {
+	*MemoryTransport
+	missing string
+}
+
+func (t missingQueueTransport) Dial() (Session, error) {
+	s, err := t.MemoryTransport.Dial()
+	return missingQueueSession{s, t.missing}, err
+}
+
+type missingQueueSession struct {
+	Session
+	missing string
+}
+
+func (s missingQueueSession) Consume(queue string, autoAck bool, prefetch int) (<-chan Delivery, error) {
+	if queue == s.missing {
+		return nil, errors.New("NOT_FOUND - no queue " + queue)
+	}
+	return s.Session.Consume(queue, autoAck, prefetch)
+}
+
+func TestFailedSubscribeIsNotRegistered(t *testing.T) {
+	transport := NewMemoryTransport()
+	client, err := NewClientWithTransport("login", missingQueueTransport{transport, "login_deals"}, WithLogger(nil),
+		WithReconnectPolicy(ReconnectPolicy{InitialInterval: 10 * time.Millisecond, MaxInterval: 10 * time.Millisecond, Multiplier: 1}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeTestClient(client)
+
+	for i := 0; i < 2; i++ {
+		if err := client.InitDeals(); err == nil || strings.Contains(err.Error(), "already subscribed") {
+			t.Fatalf("unexpected error of attempt %d: %v", i, err)
+		}
+	}
+	if client.Deals != nil {
+		t.Fatal("Deals channel is set for failed subscription")
+	}
+
+	events := client.NotifyConnectionState(make(chan ConnectionEvent, 10))
+	transport.Disconnect(errors.New("broker is gone"))
+
+	deadline := time.After(2 * time.Second)
+	for connected := false; !connected; {
+		select {
+		case e := <-events:
+			connected = e.State == StateConnected
+		case <-deadline:
+			t.Fatalf("client is %s after disconnect", client.State())
+		}
+	}
+}
+
+func TestConcurrentInitRegistersOnce(t *testing.T) {
+	client, _ := newTestClient(t)
+	defer closeTestClient(client)
+
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			errs <- client.InitOffers()
+		}()
+	}
+
+	var ok int
+	for i := 0; i < 10; i++ {
+		if err := <-errs; err == nil {
+			ok++
+		}
+	}
+	if ok != 1 {
+		t.Fatalf("%d of concurrent InitOffers succeeded", ok)
+	}
+}
@@ -0,0 +1,273 @@
+package cwapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Returned by TokenStore.Get and Client.Token when user hasn't granted token yet or it was invalidated.
+var ErrNoToken = errors.New("cwapi: no token for user")
+
+// Token granted by user to your application.
+type TokenInfo struct {
+	UserID    int       `json:"userId"`
+	Token     string    `json:"token"`
+	GrantedAt time.Time `json:"grantedAt"`
+	// Additional operations granted by user, see AuthAdditionalOperation
+	Operations []string `json:"operations,omitempty"`
+	// Additional operations waiting for user code, keyed by requestId (UUID of authAdditionalOperation response)
+	Pending map[string]string `json:"pending,omitempty"`
+}
+
+// Reports whether user granted additional operation.
+func (t *TokenInfo) HasOperation(operation string) bool {
+	for _, op := range t.Operations {
+		if op == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// Keeps tokens keyed by Telegram userID. Client fills it from grantToken, authAdditionalOperation
+// and grantAdditionalOperation responses and deletes tokens answered with InvalidToken, see WithTokenStore.
+// Implementations must be safe for concurrent use.
+type TokenStore interface {
+	// Returns ErrNoToken if there is no token for the user
+	Get(userID int) (*TokenInfo, error)
+	Put(info *TokenInfo) error
+	Delete(userID int) error
+}
+
+// Keeps tokens in memory, they are lost on restart.
+type MemoryTokenStore struct {
+	mu     sync.RWMutex
+	tokens map[int]*TokenInfo
+}
+
+// Creates empty in-memory store.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		tokens: make(map[int]*TokenInfo),
+	}
+}
+
+func (s *MemoryTokenStore) Get(userID int) (*TokenInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	info, found := s.tokens[userID]
+	if !found {
+		return nil, ErrNoToken
+	}
+	return info.copy(), nil
+}
+
+func (s *MemoryTokenStore) Put(info *TokenInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[info.UserID] = info.copy()
+	return nil
+}
+
+func (s *MemoryTokenStore) Delete(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tokens, userID)
+	return nil
+}
+
+// Keeps tokens in JSON file, it's rewritten on every change.
+type FileTokenStore struct {
+	mu     sync.RWMutex
+	path   string
+	tokens map[int]*TokenInfo
+}
+
+// Opens store backed by file, it's created on first change if it doesn't exist.
+func NewFileTokenStore(path string) (*FileTokenStore, error) {
+	s := &FileTokenStore{
+		path:   path,
+		tokens: make(map[int]*TokenInfo),
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens map[string]*TokenInfo
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, err
+	}
+	for _, info := range tokens {
+		s.tokens[info.UserID] = info
+	}
+
+	return s, nil
+}
+
+func (s *FileTokenStore) Get(userID int) (*TokenInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	info, found := s.tokens[userID]
+	if !found {
+		return nil, ErrNoToken
+	}
+	return info.copy(), nil
+}
+
+func (s *FileTokenStore) Put(info *TokenInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[info.UserID] = info.copy()
+	return s.saveLocked()
+}
+
+func (s *FileTokenStore) Delete(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.tokens[userID]; !found {
+		return nil
+	}
+	delete(s.tokens, userID)
+	return s.saveLocked()
+}
+
+// Writes tokens to temporary file and renames it, so file is never left half-written.
+func (s *FileTokenStore) saveLocked() error {
+	tokens := make(map[string]*TokenInfo, len(s.tokens))
+	for userID, info := range s.tokens {
+		tokens[strconv.Itoa(userID)] = info
+	}
+
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Returns deep copy, so stored value isn't changed by callers.
+func (t *TokenInfo) copy() *TokenInfo {
+	c := *t
+	c.Operations = append([]string(nil), t.Operations...)
+	if t.Pending != nil {
+		c.Pending = make(map[string]string, len(t.Pending))
+		for requestID, operation := range t.Pending {
+			c.Pending[requestID] = operation
+		}
+	}
+	return &c
+}
+
+// Returns token of the user from TokenStore.
+func (c *Client) Token(userID int) (string, error) {
+	info, err := c.TokenInfo(userID)
+	if err != nil {
+		return "", err
+	}
+	return info.Token, nil
+}
+
+// Returns token of the user with its grant time and operations from TokenStore.
+func (c *Client) TokenInfo(userID int) (*TokenInfo, error) {
+	if c.tokens == nil {
+		return nil, errors.New("cwapi: token store is not set, see WithTokenStore")
+	}
+	return c.tokens.Get(userID)
+}
+
+// Updates TokenStore according to the response.
+func (c *Client) trackToken(res *Response, userID int) {
+	if c.tokens == nil {
+		return
+	}
+
+	var err error
+	switch res.GetResultEnum() {
+	case Ok:
+		switch res.GetActionEnum() {
+		case GrantToken:
+			err = c.tokens.Put(&TokenInfo{
+				UserID:    userID,
+				Token:     res.Payload.ResGrantToken.Token,
+				GrantedAt: time.Now(),
+			})
+		case AuthAdditionalOperation:
+			err = c.updateToken(userID, func(info *TokenInfo) {
+				if info.Pending == nil {
+					info.Pending = make(map[string]string)
+				}
+				info.Pending[res.UUID] = res.Payload.ResAuthAdditionalOperation.Operation
+			})
+		case GrantAdditionalOperation:
+			requestID := res.Payload.ResGrantAdditionalOperation.RequestID
+			err = c.updateToken(userID, func(info *TokenInfo) {
+				operation, found := info.Pending[requestID]
+				if !found {
+					return
+				}
+				delete(info.Pending, requestID)
+				if !info.HasOperation(operation) {
+					info.Operations = append(info.Operations, operation)
+				}
+			})
+		}
+	case InvalidToken:
+		c.invalidateToken(userID)
+	}
+
+	if err != nil {
+		c.logger.Warn("cannot update token store",
+			"action", res.Action,
+			"user_id", userID,
+			"error", err,
+		)
+	}
+}
+
+// Applies change to stored token, does nothing if there is no token for the user.
+func (c *Client) updateToken(userID int, change func(info *TokenInfo)) error {
+	info, err := c.tokens.Get(userID)
+	if err == ErrNoToken {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	change(info)
+	return c.tokens.Put(info)
+}
+
+// Deletes token server answered with InvalidToken.
+func (c *Client) invalidateToken(userID int) {
+	if c.tokens == nil || userID == 0 {
+		return
+	}
+
+	if err := c.tokens.Delete(userID); err != nil {
+		c.logger.Warn("cannot delete invalid token", "user_id", userID, "error", err)
+		return
+	}
+	c.logger.Info("token invalidated", "user_id", userID)
+}
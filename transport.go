@@ -0,0 +1,101 @@
+package cwapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Returned by Session.Publish when session or its publish channel is already closed.
+// Client waits for reconnection and repeats the request once in that case.
+var ErrClosed = errors.New("cwapi: session is closed")
+
+// Returned by Session.Publish when broker couldn't accept the message.
+var ErrNacked = errors.New("cwapi: request was not acknowledged by broker")
+
+// Returned by Session.Publish when broker couldn't route the message to any queue,
+// e.g. exchange has no binding for your application.
+type ReturnedError struct {
+	Exchange   string
+	RoutingKey string
+	Code       int
+	Reason     string
+}
+
+func (e *ReturnedError) Error() string {
+	return fmt.Sprintf("cwapi: request to %s with key %s was returned: %d %s", e.Exchange, e.RoutingKey, e.Code, e.Reason)
+}
+
+// Transport opens sessions to the broker. Client calls Dial on start and after every disconnect.
+// AMQPTransport is used by default, MemoryTransport lets you test your bot without live broker.
+type Transport interface {
+	Dial() (Session, error)
+}
+
+// Single connection to the broker.
+type Session interface {
+	// Publishes message to the exchange and returns once broker has acknowledged it.
+	// If ctx is done earlier, ctx error is returned and message may be delivered or not.
+	Publish(ctx context.Context, msg Publishing) error
+	// Starts consuming queue. Returned channel is closed together with session.
+	// Positive prefetch limits number of unacknowledged deliveries of this consumer.
+	Consume(queue string, autoAck bool, prefetch int) (<-chan Delivery, error)
+	// Stops all consumers, their channels are closed once already received deliveries are drained.
+	// Session stays open, so those deliveries can be still acknowledged.
+	Cancel() error
+	// Returned channel receives the reason once session is closed, consumer is cancelled
+	// or session becomes unusable in any other way.
+	NotifyClose() <-chan error
+	Close() error
+}
+
+// Message sent to the broker.
+type Publishing struct {
+	Exchange      string
+	RoutingKey    string
+	CorrelationID string
+	Body          []byte
+}
+
+// Acknowledges deliveries, it's implemented by amqp.Channel as well.
+type Acknowledger interface {
+	Ack(tag uint64, multiple bool) error
+	Nack(tag uint64, multiple bool, requeue bool) error
+	Reject(tag uint64, requeue bool) error
+}
+
+// Message received from the queue.
+type Delivery struct {
+	Acknowledger Acknowledger
+
+	RoutingKey    string
+	CorrelationID string
+	DeliveryTag   uint64
+	Body          []byte
+}
+
+var errDeliveryNotInitialized = errors.New("cwapi: delivery not initialized")
+
+// Acknowledges delivery.
+func (d Delivery) Ack(multiple bool) error {
+	if d.Acknowledger == nil {
+		return errDeliveryNotInitialized
+	}
+	return d.Acknowledger.Ack(d.DeliveryTag, multiple)
+}
+
+// Negatively acknowledges delivery, requeue puts it back to the queue.
+func (d Delivery) Nack(multiple bool, requeue bool) error {
+	if d.Acknowledger == nil {
+		return errDeliveryNotInitialized
+	}
+	return d.Acknowledger.Nack(d.DeliveryTag, multiple, requeue)
+}
+
+// Rejects delivery, requeue puts it back to the queue.
+func (d Delivery) Reject(requeue bool) error {
+	if d.Acknowledger == nil {
+		return errDeliveryNotInitialized
+	}
+	return d.Acknowledger.Reject(d.DeliveryTag, requeue)
+}
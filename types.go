@@ -2,7 +2,6 @@ package cwapi
 
 import (
 	"encoding/json"
-	"github.com/streadway/amqp"
 	"sync"
 	"time"
 )
@@ -12,6 +11,9 @@ const (
 	CW3 = "amqps://%s:%s@api.chtwrs.com:5673/"
 )
 
+// Default time to wait for the response in Sync methods
+const defaultSyncTimeout = 10 * time.Second
+
 type ActionEnum string
 
 const (
@@ -37,40 +39,10 @@ const (
 
 // Returns constant with ActionEnum
 func (res *Response) GetActionEnum() ActionEnum {
-	switch res.Action {
-	case "createAuthCode":
-		return CreateAuthCode
-	case "grantToken":
-		return GrantToken
-	case "authAdditionalOperation":
-		return AuthAdditionalOperation
-	case "grantAdditionalOperation":
-		return GrantAdditionalOperation
-	case "authorizePayment":
-		return AuthorizePayment
-	case "pay":
-		return Pay
-	case "payout":
-		return Payout
-	case "getInfo":
-		return GetInfo
-	case "viewCraftbook":
-		return ViewCraftbook
-	case "requestProfile":
-		return RequestProfile
-	case "requestBasicInfo":
-		return RequestBasicInfo
-	case "requestGearInfo":
-		return RequestGearInfo
-	case "requestStock":
-		return RequestStock
-	case "guildInfo":
-		return GuildInfo
-	case "wantToBuy":
-		return WantToBuy
-	default:
-		return UnknownAction
+	if _, found := LookupAction(ActionEnum(res.Action)); found {
+		return ActionEnum(res.Action)
 	}
+	return UnknownAction
 }
 
 type ResultEnum string
@@ -155,6 +127,16 @@ func (res *Response) GetResultEnum() ResultEnum {
 	}
 }
 
+// Unmarshals raw payload into v, so you can decode fields and actions this library doesn't know yet:
+//
+//	var payload struct {
+//		NewField string `json:"newField"`
+//	}
+//	err := res.DecodePayload(&payload)
+func (res *Response) DecodePayload(v interface{}) error {
+	return json.Unmarshal(res.RawPayload, v)
+}
+
 type Client struct {
 	User      string
 	Password  string
@@ -168,10 +150,36 @@ type Client struct {
 	YellowPages   chan []YellowPage
 	AuctionDigest chan []AuctionDigestItem
 
-	waiters           sync.Map
-	connection        *amqp.Connection
-	channelForUpdates *amqp.Channel
-	channelForPublish *amqp.Channel
+	DecodeErrors chan DecodeError
+
+	waiters          waiterRegistry
+	mu               sync.RWMutex
+	transport        Transport
+	session          Session
+	reconnected      chan struct{}
+	done             chan struct{}
+	abort            chan struct{}
+	closing          bool
+	closeOnce        sync.Once
+	closeErr         error
+	consumers        sync.WaitGroup
+	reconnectPolicy  ReconnectPolicy
+	retryPolicy      RetryPolicy
+	router           *Router
+	subscribeMu      sync.Mutex
+	subscriptions    map[Stream]*subscription
+	updates          *sink
+	sinks            map[Stream]*sink
+	overflowPolicies map[Stream]OverflowPolicy
+	spillDir         string
+	deadLetter       *deadLetterFile
+	bufferSizes      map[Stream]int
+	syncTimeout      time.Duration
+	logger           Logger
+	tokens           TokenStore
+	ledger           *Ledger
+	state            ConnectionState
+	stateListeners   []chan ConnectionEvent
 }
 
 // Deals block
@@ -275,22 +283,15 @@ type AuctionDigestItem struct {
 	Stats        map[string]int `json:"stats"`
 }
 
-type reqPayload struct {
-	*reqCreateAuthCode
-	*reqGrantToken
-	*reqAuthAdditionalOperation
-	*reqGrantAdditionalOperation
-	*reqAuthorizePayment
-	*reqPay
-	*reqPayout
-	*reqWantToBuy
-}
-
 type Response struct {
 	UUID    string     `json:"uuid"`
 	Action  string     `json:"action"`
 	Result  string     `json:"result"`
 	Payload resPayload `json:"payload"`
+	// Payload as server sent it, including fields and actions this library doesn't know yet
+	RawPayload json.RawMessage `json:"-"`
+	// Payload decoded into response type of registered action, e.g. *ResPay, see RegisterAction
+	Decoded interface{} `json:"-"`
 }
 
 type resPayload struct {
@@ -0,0 +1,119 @@
+package cwapi
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+)
+
+// Route used to match response when server doesn't echo correlation ID back
+type route struct {
+	action string
+	userID int
+}
+
+// Sync request waiting for its response
+type waiter struct {
+	correlationID string
+	route         route
+	response      chan reply
+}
+
+// Decoded response along with raw delivery body
+type reply struct {
+	response Response
+	body     []byte
+}
+
+// Keeps pending Sync requests. Responses are matched by AMQP correlation ID first,
+// then by action and userID in FIFO order, so any number of in-flight calls per user is safe.
+type waiterRegistry struct {
+	mu      sync.Mutex
+	byID    map[string]*waiter
+	byRoute map[route][]*waiter
+}
+
+// Registers new waiter with unique correlation ID.
+func (r *waiterRegistry) add(action string, userID int) *waiter {
+	w := &waiter{
+		correlationID: newCorrelationID(),
+		route:         route{action, userID},
+		// buffered, so resolving never blocks consumer
+		response: make(chan reply, 1),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.byID == nil {
+		r.byID = make(map[string]*waiter)
+		r.byRoute = make(map[route][]*waiter)
+	}
+	r.byID[w.correlationID] = w
+	r.byRoute[w.route] = append(r.byRoute[w.route], w)
+
+	return w
+}
+
+// Removes waiter, e.g. when its context is done.
+func (r *waiterRegistry) remove(w *waiter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.removeLocked(w)
+}
+
+func (r *waiterRegistry) removeLocked(w *waiter) {
+	delete(r.byID, w.correlationID)
+
+	queue := r.byRoute[w.route]
+	for i := range queue {
+		if queue[i] == w {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) == 0 {
+		delete(r.byRoute, w.route)
+	} else {
+		r.byRoute[w.route] = queue
+	}
+}
+
+// Sends response to the matching waiter. Returns false if nobody waits for it.
+// Fallback route is used only for responses without correlation ID, response with unknown one
+// is a late reply to async or timed out request and must not be handed to other waiter.
+func (r *waiterRegistry) resolve(correlationID string, fallback route, rep reply) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, found := r.byID[correlationID]
+	if !found {
+		if correlationID != "" {
+			return false
+		}
+
+		queue := r.byRoute[fallback]
+		if len(queue) == 0 {
+			return false
+		}
+		w = queue[0]
+	}
+
+	r.removeLocked(w)
+	w.response <- rep
+
+	return true
+}
+
+// Generates random (version 4) UUID.
+func newCorrelationID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,105 @@
+package cwapi
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Creates client on top of MemoryTransport, close it with closeTestClient.
+func newTestClient(t *testing.T, opts ...Option) (*Client, *MemoryTransport) {
+	transport := NewMemoryTransport()
+	client, err := NewClientWithTransport("login", transport, append([]Option{WithLogger(nil)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client, transport
+}
+
+func closeTestClient(client *Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	client.Close(ctx)
+}
+
+func TestLateReplyIsNotHandedToOtherWaiter(t *testing.T) {
+	client, transport := newTestClient(t)
+	defer closeTestClient(client)
+
+	if err := client.RequestStock("tok"); err != nil {
+		t.Fatal(err)
+	}
+	async := <-transport.Requests()
+
+	go func() {
+		sync := <-transport.Requests()
+		transport.Reply("login", async, Ok, ResRequestStock{UserID: 1, Stock: map[string]int{"async": 1}})
+		transport.Reply("login", sync, Ok, ResRequestStock{UserID: 1, Stock: map[string]int{"sync": 1}})
+	}()
+
+	res, err := client.RequestStockSync("tok", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := res.Payload.ResRequestStock.Stock["sync"]; !found {
+		t.Fatalf("got payload of another request: %v", res.Payload.ResRequestStock.Stock)
+	}
+}
+
+func TestResponseWithoutCorrelationIDMatchesByRoute(t *testing.T) {
+	client, transport := newTestClient(t)
+	defer closeTestClient(client)
+
+	go func() {
+		<-transport.Requests()
+		transport.DeliverRaw("login_i", "", []byte(`{"action":"requestStock","result":"Ok","payload":{"userId":1,"stock":{"01":2}}}`))
+	}()
+
+	res, err := client.RequestStockSync("tok", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Payload.ResRequestStock.Stock["01"] != 2 {
+		t.Fatalf("unexpected payload: %v", res.Payload.ResRequestStock.Stock)
+	}
+}
+
+func TestConcurrentRequestsAreMatchedByCorrelationID(t *testing.T) {
+	client, transport := newTestClient(t)
+	defer closeTestClient(client)
+
+	go func() {
+		first := <-transport.Requests()
+		second := <-transport.Requests()
+		// answer in reverse order
+		transport.Reply("login", second, Ok, ResRequestProfile{UserID: 2})
+		transport.Reply("login", first, Ok, ResRequestProfile{UserID: 1})
+	}()
+
+	type result struct {
+		userID int
+		res    *Response
+		err    error
+	}
+	results := make(chan result, 2)
+	for _, userID := range []int{1, 2} {
+		go func(userID int) {
+			res, err := client.RequestProfileSync("tok", userID)
+			results <- result{userID, res, err}
+		}(userID)
+		// keep publish order deterministic
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	for i := 0; i < 2; i++ {
+		r := <-results
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		if r.res.Payload.ResRequestProfile.UserID != r.userID {
+			t.Fatalf("user %d got response of user %d", r.userID, r.res.Payload.ResRequestProfile.UserID)
+		}
+	}
+}